@@ -0,0 +1,138 @@
+package acl
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCommandRules(t *testing.T) {
+	r := NewRegistry(nil)
+	if err := r.SetUser("app", "on", ">secret", "~cache:*", "+@read", "-hget", "+config|get"); err != nil {
+		t.Fatalf("SetUser: %v", err)
+	}
+	u := r.User("app")
+
+	tests := []struct {
+		command    string
+		categories []string
+		want       bool
+	}{
+		{"get", []string{"read", "string", "fast"}, true},
+		{"hget", []string{"read", "hash", "fast"}, false},
+		{"set", []string{"write", "string", "slow"}, false},
+		{"config|get", []string{"admin", "slow"}, true},
+		{"config|set", []string{"admin", "slow"}, false},
+	}
+	for _, tt := range tests {
+		if got := u.CanRun(tt.command, tt.categories); got != tt.want {
+			t.Errorf("CanRun(%q) = %v, want %v", tt.command, got, tt.want)
+		}
+	}
+
+	if got := u.Commands(); got != "-@all +@read -hget +config|get" {
+		t.Errorf("Commands() = %q", got)
+	}
+}
+
+func TestKeyPatterns(t *testing.T) {
+	r := NewRegistry(nil)
+	if err := r.SetUser("svc", "~cache:*", "%R~shared:*"); err != nil {
+		t.Fatalf("SetUser: %v", err)
+	}
+	u := r.User("svc")
+
+	if !u.CanAccessKey("cache:1", true) || !u.CanAccessKey("cache:1", false) {
+		t.Error("expected full access to cache:1")
+	}
+	if !u.CanAccessKey("shared:1", false) || u.CanAccessKey("shared:1", true) {
+		t.Error("expected read-only access to shared:1")
+	}
+	if u.CanAccessKey("other", false) {
+		t.Error("expected no access to other")
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	r := NewRegistry(nil)
+	if r.Authenticate(DefaultUser, "anything") == nil {
+		t.Error("the default user should accept any password")
+	}
+
+	r.SetUser("alice", "on", ">pw1", ">pw2")
+	if r.Authenticate("alice", "pw2") == nil || r.Authenticate("alice", "wrong") != nil {
+		t.Error("password check failed")
+	}
+	r.SetUser("alice", "<pw2")
+	if r.Authenticate("alice", "pw2") != nil {
+		t.Error("removed password still accepted")
+	}
+	r.SetUser("alice", "off")
+	if r.Authenticate("alice", "pw1") != nil {
+		t.Error("disabled user authenticated")
+	}
+}
+
+func TestSetUserIsAtomic(t *testing.T) {
+	r := NewRegistry(func(name string) bool { return name == "get" })
+	if err := r.SetUser("bob", "on", "+get", "+nosuchcommand"); err == nil {
+		t.Fatal("expected an error for an unknown command")
+	}
+	if r.User("bob") != nil {
+		t.Error("failed SETUSER created the user")
+	}
+	if _, err := r.DelUser(DefaultUser); err == nil {
+		t.Error("expected an error deleting the default user")
+	}
+}
+
+func TestFileRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.acl")
+	r := NewRegistry(nil)
+	r.SetUser("svc", "on", ">secret", "~cache:*", "%W~queue:*", "-@all", "+get", "+set")
+	if err := r.SaveFile(path); err != nil {
+		t.Fatalf("SaveFile: %v", err)
+	}
+
+	loaded := NewRegistry(nil)
+	if err := loaded.LoadFile(path); err != nil {
+		t.Fatalf("LoadFile: %v", err)
+	}
+	for _, name := range []string{DefaultUser, "svc"} {
+		if got, want := loaded.User(name).Describe(), r.User(name).Describe(); got != want {
+			t.Errorf("%s: got %q, want %q", name, got, want)
+		}
+	}
+
+	if err := os.WriteFile(path, []byte("user a on\nuser a off\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := loaded.LoadFile(path); err == nil {
+		t.Error("expected an error for a duplicate user")
+	}
+	if loaded.User("svc") == nil {
+		t.Error("failed LoadFile replaced the users")
+	}
+}
+
+func TestLogGroupsRepeatedDenials(t *testing.T) {
+	var l Log
+	l.Add("command", "toplevel", "set", "svc", "id=1", 10)
+	l.Add("key", "toplevel", "secret", "svc", "id=1", 10)
+	l.Add("command", "toplevel", "set", "svc", "id=2", 10)
+
+	entries := l.Entries(10)
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(entries))
+	}
+	if entries[0].Object != "set" || entries[0].Count != 2 || entries[0].ClientInfo != "id=2" {
+		t.Errorf("unexpected newest entry %+v", entries[0])
+	}
+
+	for i := 0; i < 5; i++ {
+		l.Add("auth", "toplevel", "AUTH", string(rune('a'+i)), "", 3)
+	}
+	if got := len(l.Entries(10)); got != 3 {
+		t.Errorf("log not trimmed: %d entries", got)
+	}
+}
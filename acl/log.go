@@ -0,0 +1,88 @@
+package acl
+
+import (
+	"sync"
+	"time"
+)
+
+// Repeated denials with the same reason, context, object and user within
+// this window are counted in a single entry instead of filling the log.
+const logGroupingWindow = 60 * time.Second
+
+type LogEntry struct {
+	Count      int
+	Reason     string
+	Context    string
+	Object     string
+	Username   string
+	ClientInfo string
+	EntryID    int64
+	Created    time.Time
+	Updated    time.Time
+}
+
+// Log records recent authentication failures and permission denials,
+// newest first, as reported by ACL LOG.
+type Log struct {
+	mu      sync.Mutex
+	entries []*LogEntry
+	nextID  int64
+}
+
+// Add records a denial. reason is "auth", "command" or "key"; the log is
+// trimmed to maxLen entries.
+func (l *Log) Add(reason, context, object, username, clientInfo string, maxLen int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	for i, entry := range l.entries {
+		if entry.Reason == reason && entry.Context == context && entry.Object == object &&
+			entry.Username == username && now.Sub(entry.Updated) < logGroupingWindow {
+			entry.Count++
+			entry.ClientInfo = clientInfo
+			entry.Updated = now
+			copy(l.entries[1:i+1], l.entries[:i])
+			l.entries[0] = entry
+			return
+		}
+	}
+
+	entry := &LogEntry{
+		Count:      1,
+		Reason:     reason,
+		Context:    context,
+		Object:     object,
+		Username:   username,
+		ClientInfo: clientInfo,
+		EntryID:    l.nextID,
+		Created:    now,
+		Updated:    now,
+	}
+	l.nextID++
+	l.entries = append([]*LogEntry{entry}, l.entries...)
+	if len(l.entries) > maxLen {
+		l.entries = l.entries[:maxLen]
+	}
+}
+
+// Entries returns up to count of the most recent entries.
+func (l *Log) Entries(count int) []LogEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if count > len(l.entries) {
+		count = len(l.entries)
+	}
+	entries := make([]LogEntry, count)
+	for i := range entries {
+		entries[i] = *l.entries[i]
+	}
+	return entries
+}
+
+func (l *Log) Reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = nil
+}
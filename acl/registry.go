@@ -0,0 +1,183 @@
+package acl
+
+import (
+	"bufio"
+	"fmt"
+	"mini-redis/protocol"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const DefaultUser = "default"
+
+// Registry holds the ACL users. Lookups return immutable snapshots, so a
+// connection can check permissions without holding the registry lock.
+type Registry struct {
+	mu           sync.RWMutex
+	users        map[string]*User
+	knownCommand func(name string) bool
+}
+
+// NewRegistry returns a registry containing only the default user, which
+// can run every command on every key without a password. knownCommand is
+// used to validate command names in rules; nil accepts any name.
+func NewRegistry(knownCommand func(name string) bool) *Registry {
+	return &Registry{
+		users:        map[string]*User{DefaultUser: defaultUser()},
+		knownCommand: knownCommand,
+	}
+}
+
+func defaultUser() *User {
+	u := newUser(DefaultUser)
+	for _, rule := range []string{"on", "nopass", "~*", "+@all"} {
+		u.apply(rule, nil)
+	}
+	return u
+}
+
+func (r *Registry) User(name string) *User {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.users[name]
+}
+
+func (r *Registry) Users() []*User {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	users := make([]*User, 0, len(r.users))
+	for _, u := range r.users {
+		users = append(users, u)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].name < users[j].name })
+	return users
+}
+
+// SetUser creates or modifies a user. The rules are applied in order to a
+// copy of the user, which only replaces the current one if all succeed.
+func (r *Registry) SetUser(name string, rules ...string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	u := newUser(name)
+	if existing, ok := r.users[name]; ok {
+		u = existing.clone()
+	}
+	for _, rule := range rules {
+		if err := u.apply(rule, r.knownCommand); err != nil {
+			return err
+		}
+	}
+	r.users[name] = u
+	return nil
+}
+
+// DelUser removes the named users and returns how many existed.
+func (r *Registry) DelUser(names ...string) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, name := range names {
+		if name == DefaultUser {
+			return 0, fmt.Errorf("The '%s' user cannot be removed", DefaultUser)
+		}
+	}
+	deleted := 0
+	for _, name := range names {
+		if _, ok := r.users[name]; ok {
+			delete(r.users, name)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// Authenticate returns the user if it exists, is enabled and accepts the
+// password, and nil otherwise.
+func (r *Registry) Authenticate(name, password string) *User {
+	u := r.User(name)
+	if u == nil || !u.enabled || !u.CheckPassword(password) {
+		return nil
+	}
+	return u
+}
+
+// LoadFile replaces every user with the ones defined in an ACL file, one
+// "user <name> <rules...>" line each. If any line is invalid nothing is
+// changed. The default user keeps its built-in rules unless the file
+// redefines it.
+func (r *Registry) LoadFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	users := map[string]*User{DefaultUser: defaultUser()}
+	redefined := map[string]bool{}
+	scanner := bufio.NewScanner(file)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		args, err := protocol.SplitInlineArgs(line)
+		if err != nil {
+			return fmt.Errorf("%s:%d: %v", path, lineNumber, err)
+		}
+		if len(args) < 2 || args[0] != "user" {
+			return fmt.Errorf("%s:%d: should start with user keyword", path, lineNumber)
+		}
+		name := args[1]
+		if redefined[name] {
+			return fmt.Errorf("%s:%d: duplicate user '%s' found", path, lineNumber, name)
+		}
+		redefined[name] = true
+		u := newUser(name)
+		for _, rule := range args[2:] {
+			if err := u.apply(rule, r.knownCommand); err != nil {
+				return fmt.Errorf("%s:%d: %v", path, lineNumber, err)
+			}
+		}
+		users[name] = u
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	r.users = users
+	r.mu.Unlock()
+	return nil
+}
+
+// SaveFile writes every user to path, replacing the file atomically.
+func (r *Registry) SaveFile(path string) error {
+	var sb strings.Builder
+	for _, u := range r.Users() {
+		sb.WriteString(u.Describe())
+		sb.WriteByte('\n')
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".redis-acl-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.WriteString(sb.String()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,366 @@
+package acl
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"mini-redis/glob"
+	"sort"
+	"strings"
+)
+
+// Categories lists every category a command can belong to, in the order
+// ACL CAT reports them.
+var Categories = []string{
+	"keyspace", "read", "write", "set", "sortedset", "list", "hash", "string",
+	"bitmap", "hyperloglog", "geo", "stream", "pubsub", "admin", "fast", "slow",
+	"blocking", "dangerous", "connection", "transaction", "scripting",
+}
+
+func validCategory(name string) bool {
+	for _, category := range Categories {
+		if category == name {
+			return true
+		}
+	}
+	return false
+}
+
+type commandRule struct {
+	allow    bool
+	category bool
+	name     string
+}
+
+func (r commandRule) String() string {
+	sign := "-"
+	if r.allow {
+		sign = "+"
+	}
+	if r.category {
+		return sign + "@" + r.name
+	}
+	return sign + r.name
+}
+
+func (r commandRule) matches(fullName string, categories []string) bool {
+	if r.category {
+		if r.name == "all" {
+			return true
+		}
+		for _, category := range categories {
+			if category == r.name {
+				return true
+			}
+		}
+		return false
+	}
+	parent, _, _ := strings.Cut(fullName, "|")
+	return r.name == fullName || r.name == parent
+}
+
+type keyPattern struct {
+	pattern string
+	read    bool
+	write   bool
+}
+
+func (p keyPattern) String() string {
+	switch {
+	case p.read && p.write:
+		return "~" + p.pattern
+	case p.read:
+		return "%R~" + p.pattern
+	default:
+		return "%W~" + p.pattern
+	}
+}
+
+// User is an immutable snapshot of an ACL user. Registry.SetUser builds a
+// modified copy instead of changing a user other connections may be
+// checking against.
+type User struct {
+	name      string
+	enabled   bool
+	noPass    bool
+	passwords []string
+	keys      []keyPattern
+	commands  []commandRule
+}
+
+func newUser(name string) *User {
+	return &User{name: name}
+}
+
+func (u *User) clone() *User {
+	copied := *u
+	copied.passwords = append([]string(nil), u.passwords...)
+	copied.keys = append([]keyPattern(nil), u.keys...)
+	copied.commands = append([]commandRule(nil), u.commands...)
+	return &copied
+}
+
+func (u *User) Name() string {
+	return u.name
+}
+
+func (u *User) Enabled() bool {
+	return u.enabled
+}
+
+func (u *User) NoPass() bool {
+	return u.noPass
+}
+
+// Passwords returns the SHA-256 hashes of the user's passwords.
+func (u *User) Passwords() []string {
+	return append([]string(nil), u.passwords...)
+}
+
+func (u *User) CheckPassword(password string) bool {
+	if u.noPass {
+		return true
+	}
+	hash := hashPassword(password)
+	for _, stored := range u.passwords {
+		if subtle.ConstantTimeCompare([]byte(stored), []byte(hash)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// CanRun reports whether the user may run the command fullName, given as
+// "name" or "parent|sub", which belongs to categories. Command rules are
+// evaluated in order and the last matching one wins.
+func (u *User) CanRun(fullName string, categories []string) bool {
+	allowed := false
+	for _, rule := range u.commands {
+		if rule.matches(fullName, categories) {
+			allowed = rule.allow
+		}
+	}
+	return allowed
+}
+
+// CanAccessKey reports whether one of the user's key patterns grants the
+// requested kind of access to key.
+func (u *User) CanAccessKey(key string, write bool) bool {
+	for _, p := range u.keys {
+		if (write && !p.write) || (!write && !p.read) {
+			continue
+		}
+		if glob.Match(p.pattern, key) {
+			return true
+		}
+	}
+	return false
+}
+
+func (u *User) Flags() []string {
+	flags := []string{"off"}
+	if u.enabled {
+		flags[0] = "on"
+	}
+	if u.noPass {
+		flags = append(flags, "nopass")
+	}
+	return flags
+}
+
+// Commands describes the command rules the way ACL LIST and ACL GETUSER
+// show them, always starting from either +@all or -@all.
+func (u *User) Commands() string {
+	rules := []string{}
+	for _, rule := range u.commands {
+		rules = append(rules, rule.String())
+	}
+	if len(u.commands) == 0 || !u.commands[0].category || u.commands[0].name != "all" {
+		rules = append([]string{"-@all"}, rules...)
+	}
+	return strings.Join(rules, " ")
+}
+
+func (u *User) Keys() string {
+	patterns := []string{}
+	for _, p := range u.keys {
+		patterns = append(patterns, p.String())
+	}
+	return strings.Join(patterns, " ")
+}
+
+// Describe renders the user as a line of rules that SETUSER or an ACL
+// file can read back.
+func (u *User) Describe() string {
+	parts := []string{"user", u.name}
+	parts = append(parts, u.Flags()...)
+	for _, hash := range u.passwords {
+		parts = append(parts, "#"+hash)
+	}
+	if len(u.keys) == 0 {
+		parts = append(parts, "resetkeys")
+	} else {
+		parts = append(parts, u.Keys())
+	}
+	parts = append(parts, u.Commands())
+	return strings.Join(parts, " ")
+}
+
+type ruleError struct {
+	rule string
+	msg  string
+}
+
+func (e *ruleError) Error() string {
+	return fmt.Sprintf("Error in ACL SETUSER modifier '%s': %s", e.rule, e.msg)
+}
+
+// apply changes the user according to a single SETUSER rule.
+// knownCommand may be nil, in which case any command name is accepted.
+func (u *User) apply(rule string, knownCommand func(string) bool) error {
+	lower := strings.ToLower(rule)
+	switch {
+	case lower == "on":
+		u.enabled = true
+	case lower == "off":
+		u.enabled = false
+	case lower == "nopass":
+		u.noPass = true
+		u.passwords = nil
+	case lower == "resetpass":
+		u.noPass = false
+		u.passwords = nil
+	case lower == "allkeys":
+		u.keys = []keyPattern{{pattern: "*", read: true, write: true}}
+	case lower == "resetkeys":
+		u.keys = nil
+	case lower == "allcommands":
+		u.commands = []commandRule{{allow: true, category: true, name: "all"}}
+	case lower == "nocommands":
+		u.commands = nil
+	case lower == "reset":
+		u.enabled, u.noPass = false, false
+		u.passwords, u.keys, u.commands = nil, nil, nil
+	case strings.HasPrefix(rule, ">"):
+		u.addPassword(hashPassword(rule[1:]))
+	case strings.HasPrefix(rule, "<"):
+		if !u.removePassword(hashPassword(rule[1:])) {
+			return &ruleError{rule, "no such password"}
+		}
+	case strings.HasPrefix(rule, "#"):
+		if !validHash(rule[1:]) {
+			return &ruleError{rule, "The password hash must be exactly 64 characters and contain only lowercase hexadecimal characters"}
+		}
+		u.addPassword(rule[1:])
+	case strings.HasPrefix(rule, "!"):
+		if !validHash(rule[1:]) {
+			return &ruleError{rule, "The password hash must be exactly 64 characters and contain only lowercase hexadecimal characters"}
+		}
+		if !u.removePassword(rule[1:]) {
+			return &ruleError{rule, "no such password"}
+		}
+	case strings.HasPrefix(rule, "~"):
+		u.addKeyPattern(keyPattern{pattern: rule[1:], read: true, write: true})
+	case strings.HasPrefix(rule, "%"):
+		perms, pattern, ok := strings.Cut(rule[1:], "~")
+		p := keyPattern{pattern: pattern}
+		for _, perm := range strings.ToUpper(perms) {
+			switch perm {
+			case 'R':
+				p.read = true
+			case 'W':
+				p.write = true
+			default:
+				ok = false
+			}
+		}
+		if !ok || (!p.read && !p.write) {
+			return &ruleError{rule, "Syntax error"}
+		}
+		u.addKeyPattern(p)
+	case strings.HasPrefix(rule, "+@") || strings.HasPrefix(rule, "-@"):
+		name := lower[2:]
+		if name != "all" && !validCategory(name) {
+			return &ruleError{rule, "Unknown command or category name in ACL"}
+		}
+		u.addCommandRule(commandRule{allow: rule[0] == '+', category: true, name: name})
+	case strings.HasPrefix(rule, "+") || strings.HasPrefix(rule, "-"):
+		name := lower[1:]
+		if name == "" || (knownCommand != nil && !knownCommand(name)) {
+			return &ruleError{rule, "Unknown command or category name in ACL"}
+		}
+		u.addCommandRule(commandRule{allow: rule[0] == '+', name: name})
+	default:
+		return &ruleError{rule, "Syntax error"}
+	}
+	return nil
+}
+
+func (u *User) addPassword(hash string) {
+	u.noPass = false
+	i := sort.SearchStrings(u.passwords, hash)
+	if i < len(u.passwords) && u.passwords[i] == hash {
+		return
+	}
+	u.passwords = append(u.passwords, "")
+	copy(u.passwords[i+1:], u.passwords[i:])
+	u.passwords[i] = hash
+}
+
+func (u *User) removePassword(hash string) bool {
+	i := sort.SearchStrings(u.passwords, hash)
+	if i == len(u.passwords) || u.passwords[i] != hash {
+		return false
+	}
+	u.passwords = append(u.passwords[:i], u.passwords[i+1:]...)
+	return true
+}
+
+func (u *User) addKeyPattern(p keyPattern) {
+	for i, existing := range u.keys {
+		if existing.pattern == p.pattern {
+			u.keys[i].read = existing.read || p.read
+			u.keys[i].write = existing.write || p.write
+			return
+		}
+	}
+	u.keys = append(u.keys, p)
+}
+
+// addCommandRule appends rule, dropping earlier rules it fully overrides:
+// those naming the same command or category, or everything when the rule
+// covers all commands.
+func (u *User) addCommandRule(rule commandRule) {
+	if rule.category && rule.name == "all" {
+		u.commands = nil
+		if rule.allow {
+			u.commands = []commandRule{rule}
+		}
+		return
+	}
+	kept := u.commands[:0]
+	for _, existing := range u.commands {
+		if existing.category != rule.category || existing.name != rule.name {
+			kept = append(kept, existing)
+		}
+	}
+	u.commands = append(kept, rule)
+}
+
+func hashPassword(password string) string {
+	sum := sha256.Sum256([]byte(password))
+	return hex.EncodeToString(sum[:])
+}
+
+func validHash(hash string) bool {
+	if len(hash) != sha256.Size*2 {
+		return false
+	}
+	for i := 0; i < len(hash); i++ {
+		if !(hash[i] >= '0' && hash[i] <= '9') && !(hash[i] >= 'a' && hash[i] <= 'f') {
+			return false
+		}
+	}
+	return true
+}
@@ -2,59 +2,97 @@ package main
 
 import (
 	"bufio"
-	"bytes"
+	"crypto/tls"
 	"fmt"
 	"log"
+	"mini-redis/protocol"
 	"net"
+	"strconv"
 	"strings"
 )
 
 type Client struct {
-	conn net.Conn
+	conn   net.Conn
+	reader *bufio.Reader
 }
 
-func NewClient(address string) (*Client, error) {
-	conn, err := net.Dial("tcp", address)
+type dialOptions struct {
+	tlsConfig *tls.Config
+}
+
+type Option func(*dialOptions)
+
+// WithTLS makes NewClient connect over TLS. Set Certificates in cfg to
+// present a client certificate to servers that require one.
+func WithTLS(cfg *tls.Config) Option {
+	return func(o *dialOptions) {
+		o.tlsConfig = cfg
+	}
+}
+
+// NewClient connects to address, either host:port or unix:///path/to/socket.
+func NewClient(address string, opts ...Option) (*Client, error) {
+	options := dialOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	network := "tcp"
+	if path, ok := strings.CutPrefix(address, "unix://"); ok {
+		network, address = "unix", path
+	}
+
+	var conn net.Conn
+	var err error
+	if options.tlsConfig != nil {
+		conn, err = tls.Dial(network, address, options.tlsConfig)
+	} else {
+		conn, err = net.Dial(network, address)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to server: %w", err)
 	}
 
-	return &Client{conn: conn}, nil
+	return &Client{conn: conn, reader: bufio.NewReader(conn)}, nil
+}
+
+func (c *Client) readValue() (protocol.Value, error) {
+	for {
+		value, err := protocol.Decode(c.reader)
+		if err != nil {
+			return protocol.Value{}, fmt.Errorf("failed to read response: %w", err)
+		}
+		if value.Type != protocol.Attribute {
+			return value, nil
+		}
+	}
 }
 
 func (c *Client) readResponse() (string, error) {
-	reader := bufio.NewReader(c.conn)
-	line, err := reader.ReadString('\n')
+	value, err := c.readValue()
 	if err != nil {
-		return "", fmt.Errorf("failed to read response: %w", err)
-	}
-	line = strings.TrimSpace(line)
-
-	switch line[0] {
-	case '+':
-		return line[1:], nil
-	case '-':
-		return "", fmt.Errorf("redis error: %s", line[1:])
-	case ':':
-		return line[1:], nil
-	case '$':
-		var length int
-		fmt.Sscanf(line, "$%d", &length)
-		if length == -1 {
-			return "", nil
-		}
-		data := make([]byte, length+2)
-		_, err := reader.Read(data)
-		if err != nil {
-			return "", fmt.Errorf("failed to read bulk string: %w", err)
+		return "", err
+	}
+	return flatten(value)
+}
+
+func flatten(value protocol.Value) (string, error) {
+	switch value.Type {
+	case protocol.Error, protocol.BulkError:
+		return "", fmt.Errorf("redis error: %s", value.Str)
+	case protocol.Integer:
+		return strconv.FormatInt(value.Int, 10), nil
+	case protocol.Double:
+		return strconv.FormatFloat(value.Float, 'g', -1, 64), nil
+	case protocol.Boolean:
+		if value.Bool {
+			return "1", nil
 		}
-		return string(data[:length]), nil
-	case '*':
-		var count int
-		fmt.Sscanf(line, "*%d", &count)
+		return "0", nil
+	case protocol.Array, protocol.Set, protocol.Push, protocol.Map:
 		var result []string
-		for i := 0; i < count; i++ {
-			resp, err := c.readResponse()
+		for _, elem := range value.Elems {
+			resp, err := flatten(elem)
 			if err != nil {
 				return "", err
 			}
@@ -62,28 +100,41 @@ func (c *Client) readResponse() (string, error) {
 		}
 		return strings.Join(result, "\n"), nil
 	}
-	return "", fmt.Errorf("unknown response type: %s", line)
+	return value.Str, nil
 }
 
-func (c *Client) sendCommand(command []string) (string, error) {
-	var buffer bytes.Buffer
-	buffer.WriteString(fmt.Sprintf("*%d\r\n", len(command)))
-	for _, arg := range command {
-		buffer.WriteString(fmt.Sprintf("$%d\r\n%s\r\n", len(arg), arg))
-	}
-
-	_, err := c.conn.Write(buffer.Bytes())
+func (c *Client) writeCommand(command []string) error {
+	_, err := c.conn.Write(protocol.Encode(protocol.NewStringArray(command), protocol.RESP2))
 	if err != nil {
-		return "", fmt.Errorf("failed to send command: %w", err)
+		return fmt.Errorf("failed to send command: %w", err)
 	}
+	return nil
+}
 
+func (c *Client) sendCommand(command []string) (string, error) {
+	if err := c.writeCommand(command); err != nil {
+		return "", err
+	}
 	return c.readResponse()
 }
 
+// Do sends an arbitrary command and returns the reply as a protocol.Value.
+// Error replies are returned as values, not as Go errors.
+func (c *Client) Do(args ...string) (protocol.Value, error) {
+	if err := c.writeCommand(args); err != nil {
+		return protocol.Value{}, err
+	}
+	return c.readValue()
+}
+
 func (c *Client) Close() error {
 	return c.conn.Close()
 }
 
+func (c *Client) Hello(proto int) (string, error) {
+	return c.sendCommand([]string{"HELLO", strconv.Itoa(proto)})
+}
+
 func (c *Client) Set(key, value string) (string, error) {
 	return c.sendCommand([]string{"SET", key, value})
 }
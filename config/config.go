@@ -0,0 +1,396 @@
+package config
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"mini-redis/glob"
+	"mini-redis/logging"
+	"mini-redis/protocol"
+	"mini-redis/tlsconfig"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Config struct {
+	Bind                 string
+	Port                 int
+	Dir                  string
+	DBFilename           string
+	SnapshotInterval     time.Duration
+	ShutdownTimeout      time.Duration
+	ProtoMaxBulkLen      int
+	ProtoMaxMultibulkLen int
+	ProtoInlineMaxSize   int
+	ACLFile              string
+	ACLLogMaxLen         int
+	TLSPort              int
+	TLSCertFile          string
+	TLSKeyFile           string
+	TLSCACertFile        string
+	TLSAuthClients       string
+	TLSProtocols         string
+	TLSCiphers           string
+	UnixSocket           string
+	UnixSocketPerm       int
+	LogLevel             string
+	LogFile              string
+	LogFormat            string
+	SlowlogLogSlowerThan int
+	SlowlogMaxLen        int
+	MetricsPort          int
+	Databases            int
+	// LatencyMonitorThreshold is in milliseconds; 0 disables the monitor.
+	LatencyMonitorThreshold int
+	// EnableProtectedConfigs allows CONFIG SET of protected parameters.
+	EnableProtectedConfigs bool
+}
+
+func Default() Config {
+	return Config{
+		Port:                 6379,
+		Dir:                  ".",
+		DBFilename:           "snapshot.json",
+		SnapshotInterval:     30 * time.Second,
+		ShutdownTimeout:      10 * time.Second,
+		ProtoMaxBulkLen:      protocol.DefaultLimits.MaxBulkLen,
+		ProtoMaxMultibulkLen: protocol.DefaultLimits.MaxMultibulkLen,
+		ProtoInlineMaxSize:   protocol.DefaultLimits.MaxInlineLen,
+		ACLLogMaxLen:         128,
+		TLSAuthClients:       "yes",
+		LogLevel:             "notice",
+		LogFormat:            "text",
+		SlowlogLogSlowerThan: 10000,
+		SlowlogMaxLen:        128,
+		Databases:            16,
+	}
+}
+
+func (c Config) SnapshotPath() string {
+	return filepath.Join(c.Dir, c.DBFilename)
+}
+
+func (c Config) ProtocolLimits() protocol.Limits {
+	return protocol.Limits{
+		MaxMultibulkLen: c.ProtoMaxMultibulkLen,
+		MaxBulkLen:      c.ProtoMaxBulkLen,
+		MaxInlineLen:    c.ProtoInlineMaxSize,
+	}
+}
+
+func (c Config) TLSOptions() tlsconfig.Options {
+	return tlsconfig.Options{
+		CertFile:    c.TLSCertFile,
+		KeyFile:     c.TLSKeyFile,
+		CACertFile:  c.TLSCACertFile,
+		AuthClients: c.TLSAuthClients,
+		Protocols:   c.TLSProtocols,
+		Ciphers:     c.TLSCiphers,
+	}
+}
+
+func (c Config) LogOptions() logging.Options {
+	return logging.Options{File: c.LogFile, Format: c.LogFormat, Level: c.LogLevel}
+}
+
+// Manager guards the live configuration. Command handlers and background
+// jobs read a consistent copy through Current, CONFIG SET goes through Set.
+type Manager struct {
+	mu       sync.RWMutex
+	path     string
+	current  Config
+	onChange []func(Config)
+}
+
+func New() *Manager {
+	return &Manager{current: Default()}
+}
+
+// Load reads a redis.conf style file: one directive per line followed by
+// its value, '#' starting a comment. An empty path yields the defaults.
+func Load(path string) (*Manager, error) {
+	m := New()
+	if path == "" {
+		return m, nil
+	}
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	m.path = absPath
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		name, value, ok, err := parseLine(scanner.Text())
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, lineNumber, err)
+		}
+		if !ok {
+			continue
+		}
+		p := lookupParam(name)
+		if p == nil {
+			return nil, fmt.Errorf("%s:%d: bad directive or wrong number of arguments '%s'", path, lineNumber, name)
+		}
+		if err := p.set(&m.current, value); err != nil {
+			return nil, fmt.Errorf("%s:%d: %s: %v", path, lineNumber, name, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func parseLine(line string) (string, string, bool, error) {
+	line = strings.TrimSpace(line)
+	if line == "" || line[0] == '#' {
+		return "", "", false, nil
+	}
+	args, err := protocol.SplitInlineArgs(line)
+	if err != nil {
+		return "", "", false, err
+	}
+	if len(args) < 2 {
+		return "", "", false, fmt.Errorf("bad directive or wrong number of arguments '%s'", line)
+	}
+	return strings.ToLower(args[0]), strings.Join(args[1:], " "), true, nil
+}
+
+func (m *Manager) Path() string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.path
+}
+
+func (m *Manager) Current() Config {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.current
+}
+
+// Get returns the name and value of every parameter matching one of the
+// patterns, sorted by name.
+func (m *Manager) Get(patterns ...string) [][2]string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := [][2]string{}
+	for _, p := range params {
+		for _, pattern := range patterns {
+			if glob.Match(strings.ToLower(pattern), p.name) {
+				result = append(result, [2]string{p.name, p.get(&m.current)})
+				break
+			}
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i][0] < result[j][0] })
+	return result
+}
+
+type SetError struct {
+	Name string
+	Msg  string
+}
+
+func (e *SetError) Error() string {
+	return fmt.Sprintf("CONFIG SET failed (possibly related to argument '%s') - %s", e.Name, e.Msg)
+}
+
+type UnknownParamError struct {
+	Name string
+}
+
+func (e *UnknownParamError) Error() string {
+	return fmt.Sprintf("Unknown option or number of arguments for CONFIG SET - '%s'", e.Name)
+}
+
+// OnChange registers fn to be called with the new configuration after
+// every successful Set, for settings that must be pushed to the component
+// using them rather than read on demand.
+func (m *Manager) OnChange(fn func(Config)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onChange = append(m.onChange, fn)
+}
+
+// Set applies name/value pairs atomically: either every parameter is
+// changed or, on the first error, none is.
+func (m *Manager) Set(pairs ...[2]string) error {
+	m.mu.Lock()
+	updated := m.current
+	for _, pair := range pairs {
+		p := lookupParam(pair[0])
+		if p == nil {
+			m.mu.Unlock()
+			return &UnknownParamError{Name: pair[0]}
+		}
+		if !p.mutable {
+			m.mu.Unlock()
+			return &SetError{Name: p.name, Msg: "can't set immutable config"}
+		}
+		if p.protected && !updated.EnableProtectedConfigs {
+			m.mu.Unlock()
+			return &SetError{Name: p.name, Msg: "can't set protected config"}
+		}
+		if err := p.set(&updated, pair[1]); err != nil {
+			m.mu.Unlock()
+			return &SetError{Name: p.name, Msg: err.Error()}
+		}
+	}
+	m.current = updated
+	callbacks := m.onChange
+	m.mu.Unlock()
+
+	for _, fn := range callbacks {
+		fn(updated)
+	}
+	return nil
+}
+
+// Override sets a parameter at startup, including immutable ones. It is
+// used for command line flags.
+func (m *Manager) Override(name, value string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p := lookupParam(name)
+	if p == nil {
+		return &UnknownParamError{Name: name}
+	}
+	return p.set(&m.current, value)
+}
+
+// RegisterFlags adds a -<name> flag for every parameter. The returned
+// function applies the flags that were set and must be called after
+// parsing, once the configuration file has been loaded.
+func RegisterFlags(fs *flag.FlagSet) func(*Manager) error {
+	values := map[string]*string{}
+	for _, p := range params {
+		values[p.name] = fs.String(p.name, "", fmt.Sprintf("override the '%s' configuration parameter", p.name))
+	}
+	return func(m *Manager) error {
+		var err error
+		fs.Visit(func(f *flag.Flag) {
+			value, ok := values[f.Name]
+			if !ok || err != nil {
+				return
+			}
+			if setErr := m.Override(f.Name, *value); setErr != nil {
+				err = fmt.Errorf("invalid value for -%s: %v", f.Name, setErr)
+			}
+		})
+		return err
+	}
+}
+
+// Rewrite updates the configuration file in place: known directives get
+// their current value, duplicates are dropped, comments and unknown lines
+// are preserved, and parameters that differ from their default but were
+// not in the file are appended.
+func (m *Manager) Rewrite() error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.path == "" {
+		return fmt.Errorf("the server is running without a config file")
+	}
+
+	existing, err := os.ReadFile(m.path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	defaults := Default()
+	written := map[string]bool{}
+	lines := []string{}
+	if len(existing) > 0 {
+		for _, line := range strings.Split(strings.TrimRight(string(existing), "\n"), "\n") {
+			name, _, ok, err := parseLine(line)
+			p := lookupParam(name)
+			if err != nil || !ok || p == nil {
+				lines = append(lines, line)
+				continue
+			}
+			if written[p.name] {
+				continue
+			}
+			written[p.name] = true
+			lines = append(lines, p.name+" "+quote(p.get(&m.current)))
+		}
+	}
+
+	appended := false
+	for _, p := range params {
+		if written[p.name] || p.get(&m.current) == p.get(&defaults) {
+			continue
+		}
+		if !appended {
+			lines = append(lines, "# Generated by CONFIG REWRITE")
+			appended = true
+		}
+		lines = append(lines, p.name+" "+quote(p.get(&m.current)))
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(m.path), ".redis-conf-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.WriteString(strings.Join(lines, "\n") + "\n"); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), m.path)
+}
+
+func quote(value string) string {
+	plain := value != ""
+	for i := 0; i < len(value) && plain; i++ {
+		ch := value[i]
+		plain = ch > ' ' && ch < 0x7f && ch != '"' && ch != '\'' && ch != '\\' && ch != '#'
+	}
+	if plain {
+		return value
+	}
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for i := 0; i < len(value); i++ {
+		switch ch := value[i]; {
+		case ch == '\\' || ch == '"':
+			sb.WriteByte('\\')
+			sb.WriteByte(ch)
+		case ch == '\n':
+			sb.WriteString(`\n`)
+		case ch == '\r':
+			sb.WriteString(`\r`)
+		case ch == '\t':
+			sb.WriteString(`\t`)
+		case ch < 0x20 || ch >= 0x7f:
+			fmt.Fprintf(&sb, `\x%02x`, ch)
+		default:
+			sb.WriteByte(ch)
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
@@ -0,0 +1,114 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadAndGet(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "redis.conf")
+	content := "# test instance\nport 6380\n\ndbfilename \"my snapshot.json\"\nsnapshot-interval 60\nproto-max-bulk-len 1mb\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	cfg := m.Current()
+	if cfg.Port != 6380 || cfg.DBFilename != "my snapshot.json" || cfg.SnapshotInterval != time.Minute || cfg.ProtoMaxBulkLen != 1024*1024 {
+		t.Errorf("unexpected config %+v", cfg)
+	}
+
+	got := m.Get("db*", "PORT")
+	want := [][2]string{{"dbfilename", "my snapshot.json"}, {"port", "6380"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Get = %q, want %q", got, want)
+	}
+}
+
+func TestLoadRejectsUnknownDirective(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "redis.conf")
+	if err := os.WriteFile(path, []byte("no-such-option yes\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err == nil {
+		t.Error("expected an error for an unknown directive")
+	}
+}
+
+func TestSetIsAtomic(t *testing.T) {
+	m := New()
+	if err := m.Set([2]string{"port", "7000"}); err == nil {
+		t.Error("expected an error setting an immutable parameter")
+	}
+	if err := m.Set([2]string{"dir", "/etc"}); err == nil || m.Current().Dir != "." {
+		t.Error("expected an error setting a protected parameter")
+	}
+	if err := m.Override("enable-protected-configs", "yes"); err != nil {
+		t.Fatal(err)
+	}
+	err := m.Set([2]string{"dbfilename", "other.json"}, [2]string{"proto-max-multibulk-len", "nope"})
+	if err == nil {
+		t.Fatal("expected an error for an invalid value")
+	}
+	if m.Current().DBFilename != "snapshot.json" {
+		t.Errorf("dbfilename changed despite failed CONFIG SET")
+	}
+	if err := m.Set([2]string{"DBFILENAME", "other.json"}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if m.Current().DBFilename != "other.json" {
+		t.Errorf("dbfilename = %q, want other.json", m.Current().DBFilename)
+	}
+}
+
+func TestRewritePreservesComments(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "redis.conf")
+	if err := os.WriteFile(path, []byte("# keep me\nport 6380\nenable-protected-configs yes\ndbfilename a.json\ndbfilename b.json\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	m, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if err := m.Set([2]string{"dbfilename", "with space.json"}, [2]string{"snapshot-interval", "5"}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if err := m.Rewrite(); err != nil {
+		t.Fatalf("Rewrite: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "# keep me\nport 6380\nenable-protected-configs yes\ndbfilename \"with space.json\"\n# Generated by CONFIG REWRITE\nsnapshot-interval 5\n"
+	if string(data) != want {
+		t.Errorf("rewritten file =\n%s\nwant\n%s", data, want)
+	}
+
+	reloaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("reloading rewritten file: %v", err)
+	}
+	if !strings.Contains(reloaded.Current().DBFilename, "with space") {
+		t.Errorf("dbfilename did not survive the rewrite: %q", reloaded.Current().DBFilename)
+	}
+}
+
+func TestParseMemoryRejectsOverflow(t *testing.T) {
+	for _, value := range []string{"99999999999gb", "9223372036854775807k"} {
+		if parsed, err := parseMemory(value); err == nil {
+			t.Errorf("parseMemory(%q) = %d, want an error", value, parsed)
+		}
+	}
+	if parsed, err := parseMemory("2gb"); err != nil || parsed != 2<<30 {
+		t.Errorf("parseMemory(2gb) = %d, %v", parsed, err)
+	}
+}
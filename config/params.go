@@ -0,0 +1,234 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"mini-redis/logging"
+	"mini-redis/tlsconfig"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type param struct {
+	name    string
+	mutable bool
+	// protected params can only be changed at runtime when
+	// enable-protected-configs is set.
+	protected bool
+	get       func(c *Config) string
+	set       func(c *Config, value string) error
+}
+
+var params = []param{
+	stringParam("bind", false, func(c *Config) *string { return &c.Bind }),
+	intParam("port", false, func(c *Config) *int { return &c.Port }, 0, 65535),
+	protect(stringParam("dir", true, func(c *Config) *string { return &c.Dir })),
+	protect(stringParam("dbfilename", true, func(c *Config) *string { return &c.DBFilename })),
+	boolParam("enable-protected-configs", false, func(c *Config) *bool { return &c.EnableProtectedConfigs }),
+	secondsParam("snapshot-interval", true, func(c *Config) *time.Duration { return &c.SnapshotInterval }),
+	secondsParam("shutdown-timeout", true, func(c *Config) *time.Duration { return &c.ShutdownTimeout }),
+	memoryParam("proto-max-bulk-len", true, func(c *Config) *int { return &c.ProtoMaxBulkLen }, 1024*1024),
+	intParam("databases", false, func(c *Config) *int { return &c.Databases }, 1, 1<<20),
+	intParam("proto-max-multibulk-len", true, func(c *Config) *int { return &c.ProtoMaxMultibulkLen }, 1, 1<<31-1),
+	memoryParam("proto-inline-max-size", true, func(c *Config) *int { return &c.ProtoInlineMaxSize }, 1024),
+	stringParam("aclfile", false, func(c *Config) *string { return &c.ACLFile }),
+	intParam("acllog-max-len", true, func(c *Config) *int { return &c.ACLLogMaxLen }, 0, 1<<31-1),
+	checkedStringParam("loglevel", true, func(c *Config) *string { return &c.LogLevel }, func(value string) error {
+		_, err := logging.ParseLevel(value)
+		return err
+	}),
+	stringParam("logfile", false, func(c *Config) *string { return &c.LogFile }),
+	checkedStringParam("log-format", false, func(c *Config) *string { return &c.LogFormat }, logging.CheckFormat),
+	intParam("slowlog-log-slower-than", true, func(c *Config) *int { return &c.SlowlogLogSlowerThan }, -1, 1<<31-1),
+	intParam("latency-monitor-threshold", true, func(c *Config) *int { return &c.LatencyMonitorThreshold }, 0, 1<<31-1),
+	intParam("slowlog-max-len", true, func(c *Config) *int { return &c.SlowlogMaxLen }, 0, 1<<31-1),
+	stringParam("unixsocket", false, func(c *Config) *string { return &c.UnixSocket }),
+	octalParam("unixsocketperm", false, func(c *Config) *int { return &c.UnixSocketPerm }),
+	intParam("metrics-port", false, func(c *Config) *int { return &c.MetricsPort }, 0, 65535),
+	intParam("tls-port", false, func(c *Config) *int { return &c.TLSPort }, 0, 65535),
+	stringParam("tls-cert-file", true, func(c *Config) *string { return &c.TLSCertFile }),
+	stringParam("tls-key-file", true, func(c *Config) *string { return &c.TLSKeyFile }),
+	stringParam("tls-ca-cert-file", true, func(c *Config) *string { return &c.TLSCACertFile }),
+	checkedStringParam("tls-auth-clients", true, func(c *Config) *string { return &c.TLSAuthClients }, func(value string) error {
+		_, err := tlsconfig.ParseAuthClients(value)
+		return err
+	}),
+	checkedStringParam("tls-protocols", true, func(c *Config) *string { return &c.TLSProtocols }, func(value string) error {
+		_, _, err := tlsconfig.ParseProtocols(value)
+		return err
+	}),
+	checkedStringParam("tls-ciphers", true, func(c *Config) *string { return &c.TLSCiphers }, func(value string) error {
+		_, err := tlsconfig.ParseCiphers(value)
+		return err
+	}),
+}
+
+func lookupParam(name string) *param {
+	name = strings.ToLower(name)
+	for i := range params {
+		if params[i].name == name {
+			return &params[i]
+		}
+	}
+	return nil
+}
+
+// protect marks p as protected: CONFIG SET of a file path could otherwise
+// make the server write anywhere it is allowed to.
+func protect(p param) param {
+	p.protected = true
+	return p
+}
+
+func boolParam(name string, mutable bool, field func(*Config) *bool) param {
+	return param{
+		name:    name,
+		mutable: mutable,
+		get: func(c *Config) string {
+			if *field(c) {
+				return "yes"
+			}
+			return "no"
+		},
+		set: func(c *Config, value string) error {
+			switch strings.ToLower(value) {
+			case "yes":
+				*field(c) = true
+			case "no":
+				*field(c) = false
+			default:
+				return fmt.Errorf("argument must be 'yes' or 'no'")
+			}
+			return nil
+		},
+	}
+}
+
+func stringParam(name string, mutable bool, field func(*Config) *string) param {
+	return param{
+		name:    name,
+		mutable: mutable,
+		get:     func(c *Config) string { return *field(c) },
+		set: func(c *Config, value string) error {
+			*field(c) = value
+			return nil
+		},
+	}
+}
+
+func checkedStringParam(name string, mutable bool, field func(*Config) *string, check func(string) error) param {
+	return param{
+		name:    name,
+		mutable: mutable,
+		get:     func(c *Config) string { return *field(c) },
+		set: func(c *Config, value string) error {
+			if err := check(value); err != nil {
+				return err
+			}
+			*field(c) = value
+			return nil
+		},
+	}
+}
+
+func intParam(name string, mutable bool, field func(*Config) *int, min, max int) param {
+	return param{
+		name:    name,
+		mutable: mutable,
+		get:     func(c *Config) string { return strconv.Itoa(*field(c)) },
+		set: func(c *Config, value string) error {
+			parsed, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("argument couldn't be parsed into an integer")
+			}
+			if parsed < min || parsed > max {
+				return fmt.Errorf("argument must be between %d and %d inclusive", min, max)
+			}
+			*field(c) = parsed
+			return nil
+		},
+	}
+}
+
+// octalParam holds file permission bits, written in octal as with chmod.
+func octalParam(name string, mutable bool, field func(*Config) *int) param {
+	return param{
+		name:    name,
+		mutable: mutable,
+		get:     func(c *Config) string { return strconv.FormatInt(int64(*field(c)), 8) },
+		set: func(c *Config, value string) error {
+			parsed, err := strconv.ParseInt(value, 8, 32)
+			if err != nil || parsed < 0 || parsed > 0777 {
+				return fmt.Errorf("argument must be an octal permission mode between 0 and 777")
+			}
+			*field(c) = int(parsed)
+			return nil
+		},
+	}
+}
+
+func secondsParam(name string, mutable bool, field func(*Config) *time.Duration) param {
+	return param{
+		name:    name,
+		mutable: mutable,
+		get:     func(c *Config) string { return strconv.FormatInt(int64(*field(c)/time.Second), 10) },
+		set: func(c *Config, value string) error {
+			parsed, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || parsed < 0 {
+				return fmt.Errorf("argument must be a non-negative number of seconds")
+			}
+			*field(c) = time.Duration(parsed) * time.Second
+			return nil
+		},
+	}
+}
+
+// memoryParam accepts plain byte counts as well as the k/kb/m/mb/g/gb
+// suffixes used throughout redis.conf.
+func memoryParam(name string, mutable bool, field func(*Config) *int, min int) param {
+	return param{
+		name:    name,
+		mutable: mutable,
+		get:     func(c *Config) string { return strconv.Itoa(*field(c)) },
+		set: func(c *Config, value string) error {
+			parsed, err := parseMemory(value)
+			if err != nil {
+				return err
+			}
+			if parsed < min {
+				return fmt.Errorf("argument must be at least %d", min)
+			}
+			*field(c) = parsed
+			return nil
+		},
+	}
+}
+
+func parseMemory(value string) (int, error) {
+	units := []struct {
+		suffix     string
+		multiplier int
+	}{
+		{"kb", 1024}, {"mb", 1024 * 1024}, {"gb", 1024 * 1024 * 1024},
+		{"k", 1000}, {"m", 1000 * 1000}, {"g", 1000 * 1000 * 1000},
+		{"b", 1},
+	}
+	lower := strings.ToLower(value)
+	multiplier := 1
+	for _, unit := range units {
+		if strings.HasSuffix(lower, unit.suffix) {
+			lower = strings.TrimSuffix(lower, unit.suffix)
+			multiplier = unit.multiplier
+			break
+		}
+	}
+	parsed, err := strconv.Atoi(lower)
+	if err != nil || parsed < 0 {
+		return 0, fmt.Errorf("argument must be a memory value")
+	}
+	if parsed > math.MaxInt/multiplier {
+		return 0, fmt.Errorf("argument is too large")
+	}
+	return parsed * multiplier, nil
+}
@@ -0,0 +1,105 @@
+package glob
+
+// Match reports whether str matches the Redis style glob pattern. It
+// supports '*', '?', character classes such as [abc], [^a-z] and
+// backslash escapes, and unlike path.Match treats '/' like any other byte.
+func Match(pattern, str string) bool {
+	matched, _ := match(pattern, str)
+	return matched
+}
+
+// match also reports whether the string was exhausted while looking for a
+// match. Once a nested '*' has tried every suffix without success, outer
+// stars cannot do better, which keeps patterns like "a*a*a*a*b" linear
+// instead of exponential.
+func match(pattern, str string) (bool, bool) {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for len(pattern) > 1 && pattern[1] == '*' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 1 {
+				return true, false
+			}
+			for i := 0; i <= len(str); i++ {
+				matched, exhausted := match(pattern[1:], str[i:])
+				if matched {
+					return true, false
+				}
+				if exhausted {
+					return false, true
+				}
+			}
+			return false, true
+		case '?':
+			if len(str) == 0 {
+				return false, true
+			}
+			str = str[1:]
+		case '[':
+			if len(str) == 0 {
+				return false, true
+			}
+			var matched bool
+			matched, pattern = matchClass(pattern[1:], str[0])
+			if !matched {
+				return false, false
+			}
+			str = str[1:]
+			continue
+		case '\\':
+			if len(pattern) >= 2 {
+				pattern = pattern[1:]
+			}
+			fallthrough
+		default:
+			if len(str) == 0 {
+				return false, true
+			}
+			if pattern[0] != str[0] {
+				return false, false
+			}
+			str = str[1:]
+		}
+		pattern = pattern[1:]
+	}
+	return len(str) == 0, len(str) == 0
+}
+
+// matchClass matches ch against the class starting right after '[' and
+// returns the pattern remaining after the closing ']'.
+func matchClass(pattern string, ch byte) (bool, string) {
+	negate := len(pattern) > 0 && pattern[0] == '^'
+	if negate {
+		pattern = pattern[1:]
+	}
+	matched := false
+	for len(pattern) > 0 && pattern[0] != ']' {
+		switch {
+		case pattern[0] == '\\' && len(pattern) >= 2:
+			if pattern[1] == ch {
+				matched = true
+			}
+			pattern = pattern[2:]
+		case len(pattern) >= 3 && pattern[1] == '-' && pattern[2] != ']':
+			start, end := pattern[0], pattern[2]
+			if start > end {
+				start, end = end, start
+			}
+			if ch >= start && ch <= end {
+				matched = true
+			}
+			pattern = pattern[3:]
+		default:
+			if pattern[0] == ch {
+				matched = true
+			}
+			pattern = pattern[1:]
+		}
+	}
+	if len(pattern) > 0 {
+		pattern = pattern[1:]
+	}
+	return matched != negate, pattern
+}
@@ -0,0 +1,178 @@
+package latency
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Like Redis, each event keeps one sample per second for its last
+// historyLen spikes; spikes within the same second keep the worst.
+const historyLen = 160
+
+type Sample struct {
+	Time     time.Time
+	Duration time.Duration
+}
+
+// Event is the latest and the all-time worst spike of one event.
+type Event struct {
+	Name   string
+	Latest Sample
+	Max    time.Duration
+}
+
+// Summary describes the spikes an event has in its history.
+type Summary struct {
+	Samples int
+	// Period is the average time between spikes.
+	Period        time.Duration
+	Avg           time.Duration
+	MeanDeviation time.Duration
+	Min           time.Duration
+	Max           time.Duration
+	// AllTimeMax also counts spikes that already left the history.
+	AllTimeMax time.Duration
+}
+
+type series struct {
+	samples [historyLen]Sample
+	head    int
+	size    int
+	max     time.Duration
+}
+
+func (s *series) latest() Sample {
+	return s.samples[(s.head-1+historyLen)%historyLen]
+}
+
+// history returns the samples oldest first.
+func (s *series) history() []Sample {
+	samples := make([]Sample, 0, s.size)
+	for i := s.size; i > 0; i-- {
+		samples = append(samples, s.samples[(s.head-i+historyLen)%historyLen])
+	}
+	return samples
+}
+
+// Monitor records latency spikes of named events. The zero value is ready
+// to use.
+type Monitor struct {
+	mu     sync.Mutex
+	events map[string]*series
+}
+
+// Add records a spike of event that took d.
+func (m *Monitor) Add(event string, d time.Duration) {
+	m.addAt(event, d, time.Now())
+}
+
+func (m *Monitor) addAt(event string, d time.Duration, now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.events == nil {
+		m.events = map[string]*series{}
+	}
+	s := m.events[event]
+	if s == nil {
+		s = &series{}
+		m.events[event] = s
+	}
+	if d > s.max {
+		s.max = d
+	}
+
+	now = now.Truncate(time.Second)
+	if s.size > 0 && s.latest().Time.Equal(now) {
+		last := &s.samples[(s.head-1+historyLen)%historyLen]
+		if d > last.Duration {
+			last.Duration = d
+		}
+		return
+	}
+	s.samples[s.head] = Sample{Time: now, Duration: d}
+	s.head = (s.head + 1) % historyLen
+	if s.size < historyLen {
+		s.size++
+	}
+}
+
+// Latest returns every event that had a spike, sorted by name.
+func (m *Monitor) Latest() []Event {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	events := make([]Event, 0, len(m.events))
+	for name, s := range m.events {
+		events = append(events, Event{Name: name, Latest: s.latest(), Max: s.max})
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].Name < events[j].Name })
+	return events
+}
+
+// History returns the spikes of event, oldest first.
+func (m *Monitor) History(event string) []Sample {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if s := m.events[event]; s != nil {
+		return s.history()
+	}
+	return nil
+}
+
+// Summarize computes the statistics LATENCY DOCTOR reports for event.
+func (m *Monitor) Summarize(event string) Summary {
+	m.mu.Lock()
+	s := m.events[event]
+	if s == nil {
+		m.mu.Unlock()
+		return Summary{}
+	}
+	samples, allTimeMax := s.history(), s.max
+	m.mu.Unlock()
+
+	summary := Summary{Samples: len(samples), AllTimeMax: allTimeMax, Min: samples[0].Duration}
+	var total time.Duration
+	for _, sample := range samples {
+		total += sample.Duration
+		summary.Min = min(summary.Min, sample.Duration)
+		summary.Max = max(summary.Max, sample.Duration)
+	}
+	summary.Avg = total / time.Duration(len(samples))
+
+	var deviation time.Duration
+	for _, sample := range samples {
+		diff := sample.Duration - summary.Avg
+		if diff < 0 {
+			diff = -diff
+		}
+		deviation += diff
+	}
+	summary.MeanDeviation = deviation / time.Duration(len(samples))
+
+	if len(samples) > 1 {
+		span := samples[len(samples)-1].Time.Sub(samples[0].Time)
+		summary.Period = span / time.Duration(len(samples)-1)
+	}
+	return summary
+}
+
+// Reset forgets the given events, or every event when none is given, and
+// returns how many had data.
+func (m *Monitor) Reset(events ...string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(events) == 0 {
+		reset := len(m.events)
+		m.events = nil
+		return reset
+	}
+	reset := 0
+	for _, event := range events {
+		if _, exists := m.events[event]; exists {
+			delete(m.events, event)
+			reset++
+		}
+	}
+	return reset
+}
@@ -0,0 +1,56 @@
+package latency
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSamplesMergePerSecond(t *testing.T) {
+	var m Monitor
+	base := time.Unix(1000, 0)
+	m.addAt("expire-cycle", 20*time.Millisecond, base)
+	m.addAt("expire-cycle", 50*time.Millisecond, base.Add(300*time.Millisecond))
+	m.addAt("expire-cycle", 10*time.Millisecond, base.Add(600*time.Millisecond))
+	m.addAt("expire-cycle", 30*time.Millisecond, base.Add(4*time.Second))
+
+	history := m.History("expire-cycle")
+	if len(history) != 2 || history[0].Duration != 50*time.Millisecond || history[1].Duration != 30*time.Millisecond {
+		t.Fatalf("History = %+v", history)
+	}
+	latest := m.Latest()
+	if len(latest) != 1 || latest[0].Latest.Duration != 30*time.Millisecond || latest[0].Max != 50*time.Millisecond {
+		t.Fatalf("Latest = %+v", latest)
+	}
+
+	s := m.Summarize("expire-cycle")
+	if s.Samples != 2 || s.Avg != 40*time.Millisecond || s.MeanDeviation != 10*time.Millisecond || s.Period != 4*time.Second {
+		t.Errorf("Summarize = %+v", s)
+	}
+}
+
+func TestHistoryIsBounded(t *testing.T) {
+	var m Monitor
+	base := time.Unix(1000, 0)
+	for i := 0; i < historyLen+10; i++ {
+		m.addAt("command", time.Duration(i+1)*time.Millisecond, base.Add(time.Duration(i)*time.Second))
+	}
+	history := m.History("command")
+	if len(history) != historyLen || history[0].Duration != 11*time.Millisecond {
+		t.Fatalf("got %d samples starting at %v", len(history), history[0].Duration)
+	}
+	if s := m.Summarize("command"); s.Min != 11*time.Millisecond || s.AllTimeMax != time.Duration(historyLen+10)*time.Millisecond {
+		t.Errorf("Summarize = %+v", s)
+	}
+}
+
+func TestReset(t *testing.T) {
+	var m Monitor
+	m.Add("a", time.Millisecond)
+	m.Add("b", time.Millisecond)
+	if n := m.Reset("a", "missing"); n != 1 {
+		t.Errorf("Reset(a, missing) = %d, want 1", n)
+	}
+	if n := m.Reset(); n != 1 || len(m.Latest()) != 0 {
+		t.Errorf("Reset() = %d, left %v", n, m.Latest())
+	}
+}
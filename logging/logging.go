@@ -0,0 +1,158 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+)
+
+// The Redis log levels mapped onto slog levels. Verbose sits between
+// debug and notice, which is slog's info level.
+const (
+	LevelDebug   = slog.LevelDebug
+	LevelVerbose = slog.Level(-2)
+	LevelNotice  = slog.LevelInfo
+	LevelWarning = slog.LevelWarn
+)
+
+var levelNames = map[slog.Level]string{
+	LevelDebug:   "debug",
+	LevelVerbose: "verbose",
+	LevelNotice:  "notice",
+	LevelWarning: "warning",
+}
+
+func ParseLevel(name string) (slog.Level, error) {
+	for level, levelName := range levelNames {
+		if strings.EqualFold(name, levelName) {
+			return level, nil
+		}
+	}
+	return 0, fmt.Errorf("argument must be one of debug, verbose, notice or warning")
+}
+
+func CheckFormat(format string) error {
+	switch strings.ToLower(format) {
+	case "text", "json":
+		return nil
+	}
+	return fmt.Errorf("argument must be one of text or json")
+}
+
+type Options struct {
+	// File is the path of the log file; empty means standard output.
+	File   string
+	Format string
+	Level  string
+}
+
+// Logger writes leveled, structured records to standard output or to a
+// file that can be reopened after rotation.
+type Logger struct {
+	*slog.Logger
+	level *slog.LevelVar
+	out   *reopenableFile
+}
+
+func New(opts Options) (*Logger, error) {
+	level, err := ParseLevel(opts.Level)
+	if err != nil {
+		return nil, err
+	}
+	if err := CheckFormat(opts.Format); err != nil {
+		return nil, err
+	}
+
+	out := &reopenableFile{path: opts.File}
+	if err := out.Reopen(); err != nil {
+		return nil, err
+	}
+
+	l := &Logger{level: &slog.LevelVar{}, out: out}
+	l.level.Set(level)
+	handlerOpts := &slog.HandlerOptions{Level: l.level, ReplaceAttr: replaceLevel}
+	if strings.EqualFold(opts.Format, "json") {
+		l.Logger = slog.New(slog.NewJSONHandler(out, handlerOpts))
+	} else {
+		l.Logger = slog.New(slog.NewTextHandler(out, handlerOpts))
+	}
+	return l, nil
+}
+
+func replaceLevel(groups []string, a slog.Attr) slog.Attr {
+	if a.Key == slog.LevelKey && len(groups) == 0 {
+		if name, ok := levelNames[a.Value.Any().(slog.Level)]; ok {
+			a.Value = slog.StringValue(name)
+		}
+	}
+	return a
+}
+
+// SetLevel changes the verbosity at runtime.
+func (l *Logger) SetLevel(name string) error {
+	level, err := ParseLevel(name)
+	if err != nil {
+		return err
+	}
+	l.level.Set(level)
+	return nil
+}
+
+// Reopen closes and reopens the log file, so that logrotate can move it
+// away and signal the server with SIGHUP.
+func (l *Logger) Reopen() error {
+	return l.out.Reopen()
+}
+
+func (l *Logger) Debug(msg string, args ...any) {
+	l.Log(context.Background(), LevelDebug, msg, args...)
+}
+
+func (l *Logger) Verbose(msg string, args ...any) {
+	l.Log(context.Background(), LevelVerbose, msg, args...)
+}
+
+func (l *Logger) Notice(msg string, args ...any) {
+	l.Log(context.Background(), LevelNotice, msg, args...)
+}
+
+func (l *Logger) Warning(msg string, args ...any) {
+	l.Log(context.Background(), LevelWarning, msg, args...)
+}
+
+type reopenableFile struct {
+	path string
+
+	mu   sync.Mutex
+	file *os.File
+}
+
+func (f *reopenableFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return os.Stdout.Write(p)
+	}
+	return f.file.Write(p)
+}
+
+func (f *reopenableFile) Reopen() error {
+	if f.path == "" {
+		return nil
+	}
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	old := f.file
+	f.file = file
+	f.mu.Unlock()
+	if old != nil {
+		old.Close()
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package logging
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestJSONOutputAndLevels(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server.log")
+	l, err := New(Options{File: path, Format: "json", Level: "verbose"})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	l.Debug("hidden")
+	l.Verbose("shown", "id", 7)
+	if err := l.SetLevel("warning"); err != nil {
+		t.Fatal(err)
+	}
+	l.Notice("hidden too")
+	l.Warning("careful")
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines: %q", len(lines), lines)
+	}
+	var record map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &record); err != nil {
+		t.Fatal(err)
+	}
+	if record["level"] != "verbose" || record["msg"] != "shown" || record["id"] != float64(7) {
+		t.Errorf("unexpected record %v", record)
+	}
+	if !strings.Contains(lines[1], `"level":"warning"`) {
+		t.Errorf("unexpected record %s", lines[1])
+	}
+}
+
+func TestReopenFollowsRotation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "server.log")
+	l, err := New(Options{File: path, Format: "text", Level: "notice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l.Notice("before")
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	l.Notice("after")
+
+	rotated, _ := os.ReadFile(path + ".1")
+	current, _ := os.ReadFile(path)
+	if !strings.Contains(string(rotated), "before") || !strings.Contains(string(current), "after") || strings.Contains(string(current), "before") {
+		t.Errorf("rotated=%q current=%q", rotated, current)
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	if _, err := ParseLevel("NOTICE"); err != nil {
+		t.Error(err)
+	}
+	if _, err := ParseLevel("info"); err == nil {
+		t.Error("expected an error for an unknown level")
+	}
+}
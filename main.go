@@ -1,202 +1,80 @@
 package main
 
 import (
-	"bufio"
+	"context"
+	"errors"
+	"flag"
 	"fmt"
-	"io"
-	"mini-redis/protocol"
-	"mini-redis/store"
-	"net"
+	"mini-redis/config"
+	"mini-redis/logging"
+	"mini-redis/server"
 	"os"
 	"os/signal"
-	"strconv"
-	"strings"
 	"syscall"
-	"time"
 )
 
-const snapshotFile = "snapshot.json"
-
 func main() {
-	kvStore := store.NewKVStore()
-
-	if err := kvStore.LoadSnapshot(snapshotFile); err != nil {
-		fmt.Printf("Error loading snapshot: %v\n", err)
-	} else {
-		fmt.Println("Snapshot loaded successfully.")
+	configFile := flag.String("config", "", "path to a redis.conf style configuration file")
+	applyFlags := config.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+
+	// Like redis-server, accept the configuration file as the first
+	// positional argument, with overrides following it.
+	path := *configFile
+	if flag.NArg() > 0 {
+		if path == "" {
+			path = flag.Arg(0)
+		}
+		flag.CommandLine.Parse(flag.Args()[1:])
 	}
-
-	go periodicSnapshot(kvStore, snapshotFile, 30*time.Second)
-
-	go handleSignals(kvStore, snapshotFile)
-
-	listener, err := net.Listen("tcp", ":6379")
+	cfg, err := config.Load(path)
 	if err != nil {
-		fmt.Println("Error starting server:", err)
-		return
+		fmt.Println("Error loading config:", err)
+		os.Exit(1)
 	}
-	defer listener.Close()
-
-	fmt.Println("Redis-like server is running on :6379...")
-	for {
-		conn, err := listener.Accept()
-		if err != nil {
-			fmt.Println("Error accepting connection:", err)
-			continue
-		}
-		go handleConnection(conn, kvStore)
+	if err := applyFlags(cfg); err != nil {
+		fmt.Println("Error loading config:", err)
+		os.Exit(1)
 	}
-}
 
-func periodicSnapshot(kvStore *store.KeyValueStore, file string, interval time.Duration) {
-	ticker := time.NewTicker(interval)
-	defer ticker.Stop()
-
-	for range ticker.C {
-		if err := kvStore.SaveSnapshot(file); err != nil {
-			fmt.Printf("Error saving snapshot: %v\n", err)
-		} else {
-			fmt.Println("Snapshot saved successfully.")
-		}
+	logger, err := logging.New(cfg.Current().LogOptions())
+	if err != nil {
+		fmt.Println("Error opening log:", err)
+		os.Exit(1)
 	}
-}
 
-func handleSignals(kvStore *store.KeyValueStore, file string) {
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-	<-sigChan
-
-	fmt.Println("\nReceived termination signal, saving snapshot...")
-	if err := kvStore.SaveSnapshot(file); err != nil {
-		fmt.Printf("Error saving snapshot during shutdown: %v\n", err)
-	} else {
-		fmt.Println("Snapshot saved successfully.")
+	srv, err := server.New(server.WithConfig(cfg), server.WithLogger(logger))
+	if err != nil {
+		logger.Warning("Error creating server", "err", err)
+		os.Exit(1)
 	}
-	os.Exit(0)
-}
 
-func handleConnection(conn net.Conn, kvStore *store.KeyValueStore) {
-	defer conn.Close()
-	reader := bufio.NewReader(conn)
+	go handleSignals(srv, logger)
 
-	for {
-		command, err := protocol.ParseRESP(reader)
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			protocol.EncodeError(conn, "ERR invalid input")
-			continue
-		}
-		response := executeCommand(kvStore, command)
-		conn.Write(response)
+	if err := srv.ListenAndServe(); !errors.Is(err, server.ErrServerClosed) {
+		logger.Warning("Error starting server", "err", err)
+		os.Exit(1)
 	}
 }
 
-func executeCommand(kvStore *store.KeyValueStore, command []string) []byte {
-	if len(command) == 0 {
-		return protocol.EncodeError(nil, "ERR empty command")
-	}
-
-	cmd := strings.ToUpper(command[0])
-	args := command[1:]
-
-	switch cmd {
-	case "SET":
-		if len(args) < 2 || len(args) > 3 {
-			return protocol.EncodeError(nil, "ERR wrong number of arguments for 'SET' command")
-		}
-		ttl := 0
-		if len(args) == 3 {
-			parsedTTL, err := strconv.Atoi(args[2])
-			if err != nil {
-				return protocol.EncodeError(nil, "ERR invalid TTL value")
+// handleSignals shuts the server down on SIGINT and SIGTERM and reopens
+// the log file on SIGHUP.
+func handleSignals(srv *server.Server, logger *logging.Logger) {
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+
+	for sig := range sigChan {
+		if sig == syscall.SIGHUP {
+			if err := logger.Reopen(); err != nil {
+				logger.Warning("Error reopening the log file", "err", err)
+			} else {
+				logger.Notice("Log file reopened")
 			}
-			ttl = parsedTTL
-		}
-		kvStore.Set(args[0], args[1], ttl)
-		return protocol.EncodeSimpleString("OK")
-	case "GET":
-		if len(args) != 1 {
-			return protocol.EncodeError(nil, "ERR wrong number of arguments for 'GET' command")
-		}
-		value, exists := kvStore.Get(args[0])
-		if !exists {
-			return protocol.EncodeBulkString("")
-		}
-		return protocol.EncodeBulkString(value)
-	case "DEL":
-		if len(args) == 0 {
-			return protocol.EncodeError(nil, "ERR wrong number of arguments for 'DEL' command")
-		}
-		for _, key := range args {
-			kvStore.Del(key)
-		}
-		return protocol.EncodeInteger(int64(len(args)))
-	case "RPUSH":
-		if len(args) < 2 {
-			return protocol.EncodeError(nil, "ERR wrong number of arguments for 'RPUSH' command")
-		}
-		length := kvStore.RPush(args[0], args[1:]...)
-		return protocol.EncodeInteger(int64(length))
-	case "LPUSH":
-		if len(args) < 2 {
-			return protocol.EncodeError(nil, "ERR wrong number of arguments for 'LPUSH' command")
-		}
-		length := kvStore.LPush(args[0], args[1:]...)
-		return protocol.EncodeInteger(int64(length))
-	case "LPOP":
-		if len(args) != 1 {
-			return protocol.EncodeError(nil, "ERR wrong number of arguments for 'LPOP' command")
-		}
-		value, exists := kvStore.LPop(args[0])
-		if !exists {
-			return protocol.EncodeBulkString("")
-		}
-		return protocol.EncodeBulkString(value)
-	case "RPOP":
-		if len(args) != 1 {
-			return protocol.EncodeError(nil, "ERR wrong number of arguments for 'RPOP' command")
-		}
-		value, exists := kvStore.RPop(args[0])
-		if !exists {
-			return protocol.EncodeBulkString("")
-		}
-		return protocol.EncodeBulkString(value)
-	case "HSET":
-		if len(args) != 3 {
-			return protocol.EncodeError(nil, "ERR wrong number of arguments for 'HSET' command")
-		}
-		kvStore.HSet(args[0], args[1], args[2])
-		return protocol.EncodeInteger(1)
-	case "HGET":
-		if len(args) != 2 {
-			return protocol.EncodeError(nil, "ERR wrong number of arguments for 'HGET' command")
-		}
-		value, exists := kvStore.HGet(args[0], args[1])
-		if !exists {
-			return protocol.EncodeBulkString("")
-		}
-		return protocol.EncodeBulkString(value)
-	case "SADD":
-		if len(args) < 2 {
-			return protocol.EncodeError(nil, "ERR wrong number of arguments for 'SADD' command")
-		}
-		count := kvStore.SAdd(args[0], args[1:]...)
-		return protocol.EncodeInteger(int64(count))
-	case "SREM":
-		if len(args) < 2 {
-			return protocol.EncodeError(nil, "ERR wrong number of arguments for 'SREM' command")
+			continue
 		}
-		count := kvStore.SRem(args[0], args[1:]...)
-		return protocol.EncodeInteger(int64(count))
-	case "SMEMBERS":
-		if len(args) != 1 {
-			return protocol.EncodeError(nil, "ERR wrong number of arguments for 'SMEMBERS' command")
+		logger.Notice("Received signal, shutting down", "signal", sig.String())
+		if err := srv.Shutdown(context.Background()); err != nil {
+			logger.Warning("Shutdown failed", "err", err)
 		}
-		members := kvStore.SMember(args[0])
-		return protocol.EncodeArray(members)
-	default:
-		return protocol.EncodeError(nil, fmt.Sprintf("ERR unknown command '%s'", cmd))
 	}
 }
@@ -0,0 +1,142 @@
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// ContentType is the media type of the Prometheus text exposition format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// LatencyBuckets are the upper bounds, in seconds, of the buckets every
+// Histogram counts into. They span the microseconds a typical command takes
+// to the second or more of a slow one.
+var LatencyBuckets = [...]float64{
+	0.00001, 0.000025, 0.00005, 0.0001, 0.00025, 0.0005,
+	0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5,
+}
+
+// Histogram counts durations into LatencyBuckets. The zero value is ready
+// to use and all methods are safe for concurrent use.
+type Histogram struct {
+	// counts has one more slot than LatencyBuckets, for the +Inf bucket.
+	counts [len(LatencyBuckets) + 1]int64
+	sumNs  int64
+}
+
+func (h *Histogram) Observe(d time.Duration) {
+	seconds := d.Seconds()
+	i := 0
+	for i < len(LatencyBuckets) && seconds > LatencyBuckets[i] {
+		i++
+	}
+	atomic.AddInt64(&h.counts[i], 1)
+	atomic.AddInt64(&h.sumNs, int64(d))
+}
+
+func (h *Histogram) Reset() {
+	for i := range h.counts {
+		atomic.StoreInt64(&h.counts[i], 0)
+	}
+	atomic.StoreInt64(&h.sumNs, 0)
+}
+
+// Writer writes metrics in the Prometheus text exposition format. Like
+// protocol.Writer it remembers the first error, so callers only check Flush.
+type Writer struct {
+	buf *bufio.Writer
+	err error
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{buf: bufio.NewWriter(w)}
+}
+
+// Family starts a metric family; every sample of the family must follow
+// before the next one starts. kind is counter, gauge or histogram.
+func (w *Writer) Family(name, kind, help string) {
+	w.write("# HELP ", name, " ", helpEscaper.Replace(help), "\n")
+	w.write("# TYPE ", name, " ", kind, "\n")
+}
+
+// Sample writes one sample. labels alternate between names and values.
+func (w *Writer) Sample(name string, value float64, labels ...string) {
+	w.write(name, formatLabels(labels, "", ""), " ", formatFloat(value), "\n")
+}
+
+// Histogram writes the bucket, sum and count samples of h.
+func (w *Writer) Histogram(name string, h *Histogram, labels ...string) {
+	var cumulative int64
+	for i := range h.counts {
+		cumulative += atomic.LoadInt64(&h.counts[i])
+		le := math.Inf(1)
+		if i < len(LatencyBuckets) {
+			le = LatencyBuckets[i]
+		}
+		w.write(name, "_bucket", formatLabels(labels, "le", formatFloat(le)), " ", strconv.FormatInt(cumulative, 10), "\n")
+	}
+	w.write(name, "_sum", formatLabels(labels, "", ""), " ", formatFloat(time.Duration(atomic.LoadInt64(&h.sumNs)).Seconds()), "\n")
+	w.write(name, "_count", formatLabels(labels, "", ""), " ", strconv.FormatInt(cumulative, 10), "\n")
+}
+
+func (w *Writer) Flush() error {
+	if w.err == nil {
+		w.err = w.buf.Flush()
+	}
+	return w.err
+}
+
+func (w *Writer) write(parts ...string) {
+	for _, part := range parts {
+		if w.err != nil {
+			return
+		}
+		_, w.err = w.buf.WriteString(part)
+	}
+}
+
+// formatLabels renders labels, plus extraName=extraValue when extraName is
+// set, as {name="value",...}.
+func formatLabels(labels []string, extraName, extraValue string) string {
+	if extraName != "" {
+		labels = append(labels[:len(labels):len(labels)], extraName, extraValue)
+	}
+	if len(labels) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteByte('{')
+	for i := 0; i+1 < len(labels); i += 2 {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(labels[i])
+		sb.WriteString(`="`)
+		sb.WriteString(labelEscaper.Replace(labels[i+1]))
+		sb.WriteByte('"')
+	}
+	sb.WriteByte('}')
+	return sb.String()
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,61 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHistogramIsCumulative(t *testing.T) {
+	var h Histogram
+	h.Observe(5 * time.Microsecond)
+	h.Observe(3 * time.Millisecond)
+	h.Observe(10 * time.Second)
+
+	var sb strings.Builder
+	w := NewWriter(&sb)
+	w.Histogram("latency_seconds", &h, "cmd", "get")
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	out := sb.String()
+	for _, want := range []string{
+		`latency_seconds_bucket{cmd="get",le="1e-05"} 1` + "\n",
+		`latency_seconds_bucket{cmd="get",le="0.0025"} 1` + "\n",
+		`latency_seconds_bucket{cmd="get",le="0.005"} 2` + "\n",
+		`latency_seconds_bucket{cmd="get",le="2.5"} 2` + "\n",
+		`latency_seconds_bucket{cmd="get",le="+Inf"} 3` + "\n",
+		`latency_seconds_sum{cmd="get"} 10.003005` + "\n",
+		`latency_seconds_count{cmd="get"} 3` + "\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in\n%s", want, out)
+		}
+	}
+
+	h.Reset()
+	sb.Reset()
+	w = NewWriter(&sb)
+	w.Histogram("latency_seconds", &h)
+	w.Flush()
+	if !strings.Contains(sb.String(), "latency_seconds_count 0\n") {
+		t.Errorf("after Reset:\n%s", sb.String())
+	}
+}
+
+func TestSamplesAndEscaping(t *testing.T) {
+	var sb strings.Builder
+	w := NewWriter(&sb)
+	w.Family("up", "gauge", "Whether the server\nis up.")
+	w.Sample("up", 1)
+	w.Sample("keys", 2.5, "type", `a"b\c`)
+	w.Flush()
+
+	want := "# HELP up Whether the server\\nis up.\n" +
+		"# TYPE up gauge\n" +
+		"up 1\n" +
+		`keys{type="a\"b\\c"} 2.5` + "\n"
+	if sb.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", sb.String(), want)
+	}
+}
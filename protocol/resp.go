@@ -2,84 +2,453 @@ package protocol
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"io"
-	"net"
+	"math"
 	"strconv"
 	"strings"
 )
 
-func EncodeSimpleString(value string) []byte {
-	return []byte("+" + value + "\r\n")
+const (
+	RESP2 = 2
+	RESP3 = 3
+)
+
+func Encode(v Value, proto int) []byte {
+	return AppendValue(nil, v, proto)
 }
 
-func EncodeError(conn net.Conn, errorMsg string) []byte {
-	return []byte("-" + errorMsg + "\r\n")
+// AppendValue appends the wire form of v to dst. Types that only exist in
+// RESP3 are downgraded to their closest RESP2 equivalent when proto is 2.
+func AppendValue(dst []byte, v Value, proto int) []byte {
+	switch v.Type {
+	case SimpleString, Error:
+		return appendLine(dst, byte(v.Type), v.Str)
+	case Integer:
+		return appendLine(dst, ':', strconv.FormatInt(v.Int, 10))
+	case BulkString:
+		if v.IsNull {
+			return appendNull(dst, proto, '$')
+		}
+		return appendBlob(dst, '$', v.Str)
+	case Array:
+		if v.IsNull {
+			return appendNull(dst, proto, '*')
+		}
+		return appendAggregate(dst, '*', len(v.Elems), v.Elems, proto)
+	case Null:
+		return appendNull(dst, proto, '$')
+	case Double:
+		if proto >= RESP3 {
+			return appendLine(dst, ',', formatDouble(v.Float))
+		}
+		return appendBlob(dst, '$', formatDouble(v.Float))
+	case Boolean:
+		if proto >= RESP3 {
+			if v.Bool {
+				return appendLine(dst, '#', "t")
+			}
+			return appendLine(dst, '#', "f")
+		}
+		if v.Bool {
+			return appendLine(dst, ':', "1")
+		}
+		return appendLine(dst, ':', "0")
+	case BigNumber:
+		if proto >= RESP3 {
+			return appendLine(dst, '(', v.Str)
+		}
+		return appendBlob(dst, '$', v.Str)
+	case BulkError:
+		if proto >= RESP3 {
+			return appendBlob(dst, '!', v.Str)
+		}
+		return appendLine(dst, '-', strings.NewReplacer("\r", " ", "\n", " ").Replace(v.Str))
+	case Verbatim:
+		if proto >= RESP3 {
+			format := v.Format
+			if format == "" {
+				format = "txt"
+			}
+			return appendBlob(dst, '=', format+":"+v.Str)
+		}
+		return appendBlob(dst, '$', v.Str)
+	case Map:
+		if proto >= RESP3 {
+			return appendAggregate(dst, '%', len(v.Elems)/2, v.Elems, proto)
+		}
+		return appendAggregate(dst, '*', len(v.Elems), v.Elems, proto)
+	case Set, Push:
+		if proto >= RESP3 {
+			return appendAggregate(dst, byte(v.Type), len(v.Elems), v.Elems, proto)
+		}
+		return appendAggregate(dst, '*', len(v.Elems), v.Elems, proto)
+	case Attribute:
+		// RESP2 has no way to carry out-of-band metadata, the reply that
+		// follows is sent on its own.
+		if proto >= RESP3 {
+			return appendAggregate(dst, '|', len(v.Elems)/2, v.Elems, proto)
+		}
+		return dst
+	}
+	return appendLine(dst, '-', fmt.Sprintf("ERR unknown reply type '%c'", v.Type))
+}
+
+func appendLine(dst []byte, prefix byte, line string) []byte {
+	dst = append(dst, prefix)
+	dst = append(dst, line...)
+	return append(dst, '\r', '\n')
 }
 
-func EncodeInteger(value int64) []byte {
-	return []byte(":" + strconv.FormatInt(value, 10) + "\r\n")
+func appendBlob(dst []byte, prefix byte, blob string) []byte {
+	dst = appendLine(dst, prefix, strconv.Itoa(len(blob)))
+	dst = append(dst, blob...)
+	return append(dst, '\r', '\n')
 }
 
-func EncodeBulkString(value string) []byte {
-	if value == "" {
-		return []byte("$-1\r\n")
+func appendNull(dst []byte, proto int, prefix byte) []byte {
+	if proto >= RESP3 {
+		return appendLine(dst, '_', "")
 	}
-	return []byte(fmt.Sprintf("$%d\r\n%s\r\n", len(value), value))
+	return appendLine(dst, prefix, "-1")
 }
 
-func EncodeArray(values []string) []byte {
-	var sb strings.Builder
-	sb.WriteString(fmt.Sprintf("*%d\r\n", len(values)))
-	for _, value := range values {
-		sb.WriteString(string(EncodeBulkString(value)))
+func appendAggregate(dst []byte, prefix byte, count int, elems []Value, proto int) []byte {
+	dst = appendLine(dst, prefix, strconv.Itoa(count))
+	for _, elem := range elems {
+		dst = AppendValue(dst, elem, proto)
 	}
-	return []byte(sb.String())
+	return dst
 }
 
-func ParseRESP(reader *bufio.Reader) ([]string, error) {
+func formatDouble(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "inf"
+	case math.IsInf(value, -1):
+		return "-inf"
+	case math.IsNaN(value):
+		return "nan"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+func Decode(reader *bufio.Reader) (Value, error) {
 	line, err := reader.ReadString('\n')
 	if err != nil {
+		return Value{}, err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if len(line) == 0 {
+		return Value{}, fmt.Errorf("empty reply line")
+	}
+
+	kind, payload := Type(line[0]), line[1:]
+	switch kind {
+	case SimpleString, Error:
+		return Value{Type: kind, Str: payload}, nil
+	case Integer:
+		value, err := strconv.ParseInt(payload, 10, 64)
+		if err != nil {
+			return Value{}, fmt.Errorf("invalid integer %q", payload)
+		}
+		return NewInteger(value), nil
+	case Null:
+		return NewNull(), nil
+	case Double:
+		value, err := strconv.ParseFloat(payload, 64)
+		if err != nil {
+			return Value{}, fmt.Errorf("invalid double %q", payload)
+		}
+		return NewDouble(value), nil
+	case Boolean:
+		if payload != "t" && payload != "f" {
+			return Value{}, fmt.Errorf("invalid boolean %q", payload)
+		}
+		return NewBoolean(payload == "t"), nil
+	case BigNumber:
+		return NewBigNumber(payload), nil
+	case BulkString, BulkError, Verbatim:
+		length, err := strconv.Atoi(payload)
+		if err != nil || length < -1 {
+			return Value{}, fmt.Errorf("invalid bulk length %q", payload)
+		}
+		if length == -1 {
+			return Value{Type: kind, IsNull: true}, nil
+		}
+		var data strings.Builder
+		data.Grow(min(length, maxPreallocBytes))
+		if _, err := io.CopyN(&data, reader, int64(length)); err != nil {
+			return Value{}, unexpectedEOF(err)
+		}
+		terminator := make([]byte, 2)
+		if _, err := io.ReadFull(reader, terminator); err != nil {
+			return Value{}, unexpectedEOF(err)
+		}
+		if terminator[0] != '\r' || terminator[1] != '\n' {
+			return Value{}, fmt.Errorf("bulk string not terminated by CRLF")
+		}
+		value := Value{Type: kind, Str: data.String()}
+		if kind == Verbatim {
+			if length < 4 || value.Str[3] != ':' {
+				return Value{}, fmt.Errorf("invalid verbatim string")
+			}
+			value.Format, value.Str = value.Str[:3], value.Str[4:]
+		}
+		return value, nil
+	case Array, Set, Push, Map, Attribute:
+		count, err := strconv.Atoi(payload)
+		if err != nil || count < -1 {
+			return Value{}, fmt.Errorf("invalid aggregate length %q", payload)
+		}
+		if count == -1 {
+			return Value{Type: kind, IsNull: true}, nil
+		}
+		if kind == Map || kind == Attribute {
+			if count > math.MaxInt/2 {
+				return Value{}, fmt.Errorf("invalid aggregate length %q", payload)
+			}
+			count *= 2
+		}
+		value := Value{Type: kind, Elems: make([]Value, 0, min(count, maxPreallocArgs))}
+		for i := 0; i < count; i++ {
+			elem, err := Decode(reader)
+			if err != nil {
+				return Value{}, err
+			}
+			value.Elems = append(value.Elems, elem)
+		}
+		return value, nil
+	}
+	return Value{}, fmt.Errorf("unknown reply type %q", line[0])
+}
+
+type Limits struct {
+	MaxMultibulkLen int
+	MaxBulkLen      int
+	MaxInlineLen    int
+}
+
+var DefaultLimits = Limits{
+	MaxMultibulkLen: 1024 * 1024,
+	MaxBulkLen:      512 * 1024 * 1024,
+	MaxInlineLen:    64 * 1024,
+}
+
+// Preallocation is capped so that a peer announcing a huge request or reply
+// only costs memory once it actually sends the data.
+const (
+	maxPreallocArgs  = 1024
+	maxPreallocBytes = 64 * 1024
+)
+
+// ProtocolError reports a malformed request. The stream cannot be trusted
+// past this point, so the connection should be closed after replying.
+type ProtocolError struct {
+	msg string
+}
+
+func (e *ProtocolError) Error() string {
+	return "Protocol error: " + e.msg
+}
+
+var errLineTooLong = errors.New("line too long")
+
+func protocolErrorf(format string, args ...interface{}) error {
+	return &ProtocolError{msg: fmt.Sprintf(format, args...)}
+}
+
+func ParseRESP(reader *bufio.Reader) ([]string, error) {
+	return ParseRESPWithLimits(reader, DefaultLimits)
+}
+
+func ParseRESPWithLimits(reader *bufio.Reader, limits Limits) ([]string, error) {
+	line, err := readLine(reader, limits.MaxInlineLen)
+	if err != nil {
+		if err == errLineTooLong {
+			return nil, protocolErrorf("too big inline request")
+		}
 		return nil, err
 	}
 
 	line = strings.TrimSpace(line)
 	if len(line) == 0 || line[0] != '*' {
-		return nil, fmt.Errorf("invalid array start")
+		return SplitInlineArgs(line)
 	}
 
 	count, err := strconv.Atoi(line[1:])
-	if err != nil {
-		return nil, fmt.Errorf("invalid array length")
+	if err != nil || count > limits.MaxMultibulkLen {
+		return nil, protocolErrorf("invalid multibulk length")
+	}
+	if count <= 0 {
+		return []string{}, nil
 	}
 
-	parts := make([]string, count)
+	parts := make([]string, 0, min(count, maxPreallocArgs))
 	for i := 0; i < count; i++ {
-		line, err := reader.ReadString('\n')
+		line, err := readLine(reader, limits.MaxInlineLen)
 		if err != nil {
-			return nil, err
+			if err == errLineTooLong {
+				return nil, protocolErrorf("too big bulk count string")
+			}
+			return nil, unexpectedEOF(err)
 		}
 
-		line = strings.TrimSpace(line)
+		line = strings.TrimRight(line, "\r\n")
 		if len(line) == 0 || line[0] != '$' {
-			return nil, fmt.Errorf("invalid bulk string start")
+			if len(line) == 0 {
+				return nil, protocolErrorf("expected '$', got ''")
+			}
+			return nil, protocolErrorf("expected '$', got '%c'", line[0])
 		}
 
 		length, err := strconv.Atoi(line[1:])
-		if err != nil || length < 0 {
-			return nil, fmt.Errorf("invalid bulk string length")
+		if err != nil || length < 0 || length > limits.MaxBulkLen {
+			return nil, protocolErrorf("invalid bulk length")
 		}
 
-		part := make([]byte, length)
-		if _, err := io.ReadFull(reader, part); err != nil {
-			return nil, err
+		var part strings.Builder
+		part.Grow(min(length, maxPreallocBytes))
+		if _, err := io.CopyN(&part, reader, int64(length)); err != nil {
+			return nil, unexpectedEOF(err)
 		}
-		parts[i] = string(part)
+		parts = append(parts, part.String())
 
-		if _, err := reader.Discard(2); err != nil {
-			return nil, err
+		terminator := make([]byte, 2)
+		if _, err := io.ReadFull(reader, terminator); err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		if terminator[0] != '\r' || terminator[1] != '\n' {
+			return nil, protocolErrorf("expected CRLF after bulk string")
 		}
 	}
 
 	return parts, nil
 }
+
+// readLine reads up to and including the next '\n' without buffering more
+// than max bytes.
+func readLine(reader *bufio.Reader, max int) (string, error) {
+	var line []byte
+	for {
+		chunk, err := reader.ReadSlice('\n')
+		if len(line)+len(chunk) > max {
+			return "", errLineTooLong
+		}
+		line = append(line, chunk...)
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil {
+			if err == io.EOF && len(line) > 0 {
+				return "", io.ErrUnexpectedEOF
+			}
+			return "", err
+		}
+		return string(line), nil
+	}
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// SplitInlineArgs splits a command typed on a single line, following the
+// quoting rules of redis-cli: double quoted strings understand the usual
+// backslash escapes and \xHH, single quoted strings only \'.
+func SplitInlineArgs(line string) ([]string, error) {
+	args := []string{}
+	i := 0
+	for {
+		for i < len(line) && isSpace(line[i]) {
+			i++
+		}
+		if i == len(line) {
+			return args, nil
+		}
+
+		var current strings.Builder
+		inDouble, inSingle, done := false, false, false
+		for !done {
+			if i == len(line) {
+				if inDouble || inSingle {
+					return nil, protocolErrorf("unbalanced quotes in request")
+				}
+				break
+			}
+			ch := line[i]
+			switch {
+			case inDouble:
+				switch {
+				case ch == '\\' && i+3 < len(line) && line[i+1] == 'x' && isHexDigit(line[i+2]) && isHexDigit(line[i+3]):
+					value, _ := strconv.ParseUint(line[i+2:i+4], 16, 8)
+					current.WriteByte(byte(value))
+					i += 3
+				case ch == '\\' && i+1 < len(line):
+					i++
+					switch line[i] {
+					case 'n':
+						current.WriteByte('\n')
+					case 'r':
+						current.WriteByte('\r')
+					case 't':
+						current.WriteByte('\t')
+					case 'b':
+						current.WriteByte('\b')
+					case 'a':
+						current.WriteByte('\a')
+					default:
+						current.WriteByte(line[i])
+					}
+				case ch == '"':
+					if i+1 < len(line) && !isSpace(line[i+1]) {
+						return nil, protocolErrorf("unbalanced quotes in request")
+					}
+					done = true
+				default:
+					current.WriteByte(ch)
+				}
+			case inSingle:
+				switch {
+				case ch == '\\' && i+1 < len(line) && line[i+1] == '\'':
+					i++
+					current.WriteByte('\'')
+				case ch == '\'':
+					if i+1 < len(line) && !isSpace(line[i+1]) {
+						return nil, protocolErrorf("unbalanced quotes in request")
+					}
+					done = true
+				default:
+					current.WriteByte(ch)
+				}
+			default:
+				switch {
+				case isSpace(ch):
+					done = true
+				case ch == '"':
+					inDouble = true
+				case ch == '\'':
+					inSingle = true
+				default:
+					current.WriteByte(ch)
+				}
+			}
+			if i < len(line) {
+				i++
+			}
+		}
+		args = append(args, current.String())
+	}
+}
+
+func isSpace(ch byte) bool {
+	return ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r' || ch == '\v' || ch == '\f'
+}
+
+func isHexDigit(ch byte) bool {
+	return (ch >= '0' && ch <= '9') || (ch >= 'a' && ch <= 'f') || (ch >= 'A' && ch <= 'F')
+}
@@ -0,0 +1,222 @@
+package protocol
+
+import (
+	"bufio"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseRESPInline(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []string
+	}{
+		{"SET foo bar\r\n", []string{"SET", "foo", "bar"}},
+		{"  GET   foo  \n", []string{"GET", "foo"}},
+		{"\r\n", []string{}},
+		{`SET key "hello world"` + "\r\n", []string{"SET", "key", "hello world"}},
+		{`SET key "a\r\nb\x00\"c"` + "\r\n", []string{"SET", "key", "a\r\nb\x00\"c"}},
+		{`SET key 'it\'s "raw"\n'` + "\r\n", []string{"SET", "key", `it's "raw"\n`}},
+		{`SET key ""` + "\r\n", []string{"SET", "key", ""}},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseRESP(bufio.NewReader(strings.NewReader(tt.input)))
+		if err != nil {
+			t.Errorf("ParseRESP(%q) returned error: %v", tt.input, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseRESP(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestParseRESPInlineUnbalancedQuotes(t *testing.T) {
+	for _, input := range []string{`SET key "value` + "\r\n", `SET key 'value` + "\r\n", `SET key "a"b` + "\r\n"} {
+		if _, err := ParseRESP(bufio.NewReader(strings.NewReader(input))); err == nil {
+			t.Errorf("ParseRESP(%q) expected an error", input)
+		}
+	}
+}
+
+func TestParseRESPMultibulk(t *testing.T) {
+	input := "*3\r\n$3\r\nSET\r\n$3\r\nfoo\r\n$3\r\nbar\r\n"
+	got, err := ParseRESP(bufio.NewReader(strings.NewReader(input)))
+	if err != nil {
+		t.Fatalf("ParseRESP returned error: %v", err)
+	}
+	if want := []string{"SET", "foo", "bar"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseRESP = %q, want %q", got, want)
+	}
+}
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	tests := []struct {
+		value Value
+		proto int
+	}{
+		{NewSimpleString("OK"), RESP2},
+		{NewError("ERR boom"), RESP2},
+		{NewInteger(-42), RESP2},
+		{NewBulkString(""), RESP2},
+		{NewBulkString("a\r\nb"), RESP2},
+		{NewNullBulkString(), RESP2},
+		{NewNullArray(), RESP2},
+		{NewArray(), RESP2},
+		{NewArray(NewInteger(1), NewBulkString("two"), NewArray(NewNullBulkString(), NewSimpleString("three"))), RESP2},
+		{NewNull(), RESP3},
+		{NewDouble(3.25), RESP3},
+		{NewBoolean(true), RESP3},
+		{NewBigNumber("3492890328409238509324850943850943825024385"), RESP3},
+		{NewVerbatim("txt", "Some string"), RESP3},
+		{NewMap(NewBulkString("key"), NewArray(NewInteger(1), NewNull())), RESP3},
+		{NewSet(NewBulkString("a"), NewBulkString("b")), RESP3},
+		{NewPush(NewBulkString("message"), NewBulkString("chan"), NewBulkString("hi")), RESP3},
+	}
+
+	for _, tt := range tests {
+		encoded := Encode(tt.value, tt.proto)
+		got, err := Decode(bufio.NewReader(strings.NewReader(string(encoded))))
+		if err != nil {
+			t.Errorf("Decode(%q) returned error: %v", encoded, err)
+			continue
+		}
+		if !reflect.DeepEqual(normalize(got), normalize(tt.value)) {
+			t.Errorf("Decode(%q) = %+v, want %+v", encoded, got, tt.value)
+		}
+	}
+}
+
+func TestDecodeRejectsBadLengths(t *testing.T) {
+	tests := []string{
+		"$9223372036854775807\r\n",
+		"$9223372036854775807\r\nabc",
+		"$-2\r\n",
+		"$99999999999999999999\r\n",
+		"*9223372036854775807\r\n",
+		"*9223372036854775807\r\n:1\r\n",
+		"%4611686018427387904\r\n",
+		"%9223372036854775807\r\n",
+		"*-2\r\n",
+		"$3\r\nabcXX",
+	}
+
+	for _, input := range tests {
+		if got, err := Decode(bufio.NewReader(strings.NewReader(input))); err == nil {
+			t.Errorf("Decode(%q) = %+v, want an error", input, got)
+		}
+	}
+}
+
+func TestEncodeRESP2Downgrade(t *testing.T) {
+	tests := []struct {
+		value Value
+		want  string
+	}{
+		{NewNullBulkString(), "$-1\r\n"},
+		{NewNullArray(), "*-1\r\n"},
+		{NewBulkString(""), "$0\r\n\r\n"},
+		{NewNull(), "$-1\r\n"},
+		{NewBoolean(true), ":1\r\n"},
+		{NewDouble(1.5), "$3\r\n1.5\r\n"},
+		{NewMap(NewBulkString("a"), NewInteger(1)), "*2\r\n$1\r\na\r\n:1\r\n"},
+		{NewAttribute(NewBulkString("a"), NewInteger(1)), ""},
+	}
+
+	for _, tt := range tests {
+		if got := string(Encode(tt.value, RESP2)); got != tt.want {
+			t.Errorf("Encode(%+v, RESP2) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func normalize(v Value) Value {
+	if len(v.Elems) == 0 {
+		v.Elems = nil
+	}
+	for i := range v.Elems {
+		v.Elems[i] = normalize(v.Elems[i])
+	}
+	return v
+}
+
+func TestParseRESPLimits(t *testing.T) {
+	limits := Limits{MaxMultibulkLen: 4, MaxBulkLen: 8, MaxInlineLen: 16}
+	tests := []string{
+		"*5\r\n",
+		"*1\r\n$9\r\n123456789\r\n",
+		"*1\r\n$-3\r\n",
+		"*1\r\n:1\r\n",
+		"*1\r\n$3\r\nfooXX",
+		"SET " + strings.Repeat("x", 32) + "\r\n",
+		"*" + strings.Repeat("1", 32) + "\r\n",
+	}
+
+	for _, input := range tests {
+		_, err := ParseRESPWithLimits(bufio.NewReader(strings.NewReader(input)), limits)
+		if _, ok := err.(*ProtocolError); !ok {
+			t.Errorf("ParseRESPWithLimits(%q) error = %v, want a ProtocolError", input, err)
+		}
+	}
+}
+
+func FuzzParseRESP(f *testing.F) {
+	f.Add([]byte("*3\r\n$3\r\nSET\r\n$3\r\nfoo\r\n$3\r\nbar\r\n"))
+	f.Add([]byte("SET foo \"bar baz\"\r\nGET foo\r\n"))
+	f.Add([]byte("*2\r\n$3\r\nGET\r\n$-1\r\n"))
+	f.Add([]byte("*-1\r\n*0\r\n"))
+	f.Add([]byte("*99999999999\r\n"))
+	f.Add([]byte("*1\r\n$99999999999999999999\r\n"))
+	f.Add([]byte("'unterminated \"\\x4"))
+	f.Add([]byte("*1\r\n$3\r\nabc"))
+
+	limits := Limits{MaxMultibulkLen: 64, MaxBulkLen: 1024, MaxInlineLen: 1024}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		reader := bufio.NewReader(strings.NewReader(string(data)))
+		for {
+			args, err := ParseRESPWithLimits(reader, limits)
+			if err != nil {
+				return
+			}
+			for _, arg := range args {
+				if len(arg) > limits.MaxBulkLen {
+					t.Fatalf("argument of %d bytes exceeds the bulk limit", len(arg))
+				}
+			}
+
+			encoded := Encode(NewStringArray(args), RESP2)
+			again, err := ParseRESP(bufio.NewReader(strings.NewReader(string(encoded))))
+			if err != nil {
+				t.Fatalf("re-parsing %q failed: %v", encoded, err)
+			}
+			if len(args) > 0 && !reflect.DeepEqual(again, args) {
+				t.Fatalf("round trip mismatch: %q != %q", again, args)
+			}
+		}
+	})
+}
+
+func FuzzDecode(f *testing.F) {
+	f.Add([]byte("*2\r\n$3\r\nfoo\r\n:42\r\n"))
+	f.Add([]byte("%1\r\n+key\r\n_\r\n"))
+	f.Add([]byte("=15\r\ntxt:Some string\r\n"))
+	f.Add([]byte("$9223372036854775807\r\n"))
+	f.Add([]byte("*9223372036854775807\r\n"))
+	f.Add([]byte("%4611686018427387904\r\n"))
+	f.Add([]byte("$3\r\nab"))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		reader := bufio.NewReader(strings.NewReader(string(data)))
+		for {
+			value, err := Decode(reader)
+			if err != nil {
+				return
+			}
+			if value.Type == BulkString && len(value.Str) > len(data) {
+				t.Fatalf("decoded %d bytes from %d bytes of input", len(value.Str), len(data))
+			}
+		}
+	})
+}
@@ -0,0 +1,127 @@
+package protocol
+
+import "fmt"
+
+type Type byte
+
+const (
+	SimpleString Type = '+'
+	Error        Type = '-'
+	Integer      Type = ':'
+	BulkString   Type = '$'
+	Array        Type = '*'
+	Null         Type = '_'
+	Double       Type = ','
+	Boolean      Type = '#'
+	BigNumber    Type = '('
+	BulkError    Type = '!'
+	Verbatim     Type = '='
+	Map          Type = '%'
+	Set          Type = '~'
+	Push         Type = '>'
+	Attribute    Type = '|'
+)
+
+// Value is a single RESP reply of any kind. Str holds the payload of the
+// string-like types, Elems the children of aggregates (maps and attributes
+// store keys and values alternately) and IsNull marks the RESP2 null bulk
+// string and null array, which differ from an empty string or array.
+type Value struct {
+	Type   Type
+	Str    string
+	Format string
+	Int    int64
+	Float  float64
+	Bool   bool
+	Elems  []Value
+	IsNull bool
+}
+
+func NewSimpleString(value string) Value {
+	return Value{Type: SimpleString, Str: value}
+}
+
+func NewError(message string) Value {
+	return Value{Type: Error, Str: message}
+}
+
+func NewErrorf(format string, args ...interface{}) Value {
+	return NewError(fmt.Sprintf(format, args...))
+}
+
+func NewInteger(value int64) Value {
+	return Value{Type: Integer, Int: value}
+}
+
+func NewBulkString(value string) Value {
+	return Value{Type: BulkString, Str: value}
+}
+
+func NewNullBulkString() Value {
+	return Value{Type: BulkString, IsNull: true}
+}
+
+func NewNullArray() Value {
+	return Value{Type: Array, IsNull: true}
+}
+
+func NewNull() Value {
+	return Value{Type: Null}
+}
+
+func NewDouble(value float64) Value {
+	return Value{Type: Double, Float: value}
+}
+
+func NewBoolean(value bool) Value {
+	return Value{Type: Boolean, Bool: value}
+}
+
+func NewBigNumber(value string) Value {
+	return Value{Type: BigNumber, Str: value}
+}
+
+func NewVerbatim(format, value string) Value {
+	return Value{Type: Verbatim, Format: format, Str: value}
+}
+
+func NewArray(values ...Value) Value {
+	if values == nil {
+		values = []Value{}
+	}
+	return Value{Type: Array, Elems: values}
+}
+
+func NewStringArray(values []string) Value {
+	elems := make([]Value, len(values))
+	for i, value := range values {
+		elems[i] = NewBulkString(value)
+	}
+	return Value{Type: Array, Elems: elems}
+}
+
+func NewMap(pairs ...Value) Value {
+	return Value{Type: Map, Elems: pairs}
+}
+
+func NewSet(members ...Value) Value {
+	return Value{Type: Set, Elems: members}
+}
+
+func NewStringSet(members []string) Value {
+	set := NewStringArray(members)
+	set.Type = Set
+	return set
+}
+
+func NewPush(values ...Value) Value {
+	return Value{Type: Push, Elems: values}
+}
+
+func NewAttribute(pairs ...Value) Value {
+	return Value{Type: Attribute, Elems: pairs}
+}
+
+func (v Value) IsError() bool {
+	return v.Type == Error || v.Type == BulkError
+}
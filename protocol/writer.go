@@ -0,0 +1,130 @@
+package protocol
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+)
+
+const writerBufferSize = 16 * 1024
+
+// Writer buffers replies for a single connection. The first failed write is
+// remembered and every later call becomes a no-op returning that error, so
+// command handlers can write freely and the connection loop only has to
+// check Err once per command.
+type Writer struct {
+	buf     *bufio.Writer
+	proto   int
+	scratch []byte
+	discard bool
+	errors  int
+	err     error
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{
+		buf:   bufio.NewWriterSize(w, writerBufferSize),
+		proto: RESP2,
+	}
+}
+
+func (w *Writer) Protocol() int {
+	return w.proto
+}
+
+func (w *Writer) SetProtocol(proto int) {
+	w.proto = proto
+}
+
+// SetDiscard makes the writer silently drop replies until it is turned off
+// again, which is how CLIENT REPLY OFF and SKIP are implemented.
+func (w *Writer) SetDiscard(discard bool) {
+	w.discard = discard
+}
+
+func (w *Writer) WriteValue(v Value) error {
+	if v.IsError() {
+		w.errors++
+	}
+	w.scratch = AppendValue(w.scratch[:0], v, w.proto)
+	return w.write(w.scratch)
+}
+
+func (w *Writer) WriteSimpleString(value string) error {
+	w.scratch = appendLine(w.scratch[:0], '+', value)
+	return w.write(w.scratch)
+}
+
+func (w *Writer) WriteError(message string) error {
+	w.errors++
+	w.scratch = appendLine(w.scratch[:0], '-', message)
+	return w.write(w.scratch)
+}
+
+func (w *Writer) WriteInteger(value int64) error {
+	w.scratch = appendLine(w.scratch[:0], ':', strconv.FormatInt(value, 10))
+	return w.write(w.scratch)
+}
+
+func (w *Writer) WriteBulkString(value string) error {
+	w.scratch = appendBlob(w.scratch[:0], '$', value)
+	return w.write(w.scratch)
+}
+
+func (w *Writer) WriteNull() error {
+	w.scratch = appendNull(w.scratch[:0], w.proto, '$')
+	return w.write(w.scratch)
+}
+
+func (w *Writer) WriteArrayHeader(count int) error {
+	w.scratch = appendLine(w.scratch[:0], '*', strconv.Itoa(count))
+	return w.write(w.scratch)
+}
+
+func (w *Writer) WriteSetHeader(count int) error {
+	if w.proto >= RESP3 {
+		w.scratch = appendLine(w.scratch[:0], '~', strconv.Itoa(count))
+		return w.write(w.scratch)
+	}
+	return w.WriteArrayHeader(count)
+}
+
+// WriteMapHeader announces count key/value pairs; on RESP2 they are sent
+// as a flat array of 2*count elements.
+func (w *Writer) WriteMapHeader(count int) error {
+	if w.proto >= RESP3 {
+		w.scratch = appendLine(w.scratch[:0], '%', strconv.Itoa(count))
+		return w.write(w.scratch)
+	}
+	return w.WriteArrayHeader(count * 2)
+}
+
+func (w *Writer) Buffered() int {
+	return w.buf.Buffered()
+}
+
+func (w *Writer) Flush() error {
+	if w.err != nil {
+		return w.err
+	}
+	w.err = w.buf.Flush()
+	return w.err
+}
+
+// Errors counts the error replies written so far, including discarded
+// ones, so callers can tell whether a command failed.
+func (w *Writer) Errors() int {
+	return w.errors
+}
+
+func (w *Writer) Err() error {
+	return w.err
+}
+
+func (w *Writer) write(p []byte) error {
+	if w.err != nil || w.discard {
+		return w.err
+	}
+	_, w.err = w.buf.Write(p)
+	return w.err
+}
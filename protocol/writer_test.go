@@ -0,0 +1,46 @@
+package protocol
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+type failingWriter struct{ writes int }
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	w.writes++
+	return 0, errors.New("broken pipe")
+}
+
+func TestWriterBuffersUntilFlush(t *testing.T) {
+	var out bytes.Buffer
+	w := NewWriter(&out)
+	w.WriteSimpleString("OK")
+	w.WriteInteger(1)
+	if out.Len() != 0 {
+		t.Fatalf("%q written before Flush", out.String())
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "+OK\r\n:1\r\n" {
+		t.Errorf("flushed %q", out.String())
+	}
+}
+
+func TestWriterRemembersErrors(t *testing.T) {
+	failing := &failingWriter{}
+	w := NewWriter(failing)
+	w.WriteSimpleString("OK")
+	if err := w.Flush(); err == nil {
+		t.Fatal("Flush succeeded on a failing writer")
+	}
+	w.WriteSimpleString("OK")
+	if err := w.Flush(); err == nil || w.Err() == nil {
+		t.Error("the error was forgotten")
+	}
+	if failing.writes != 1 {
+		t.Errorf("%d writes after the first failure, want none", failing.writes-1)
+	}
+}
@@ -0,0 +1,47 @@
+package server
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestKeyPermissionsPerPosition(t *testing.T) {
+	srv := startServer(t)
+	admin := dial(t, srv.Addr())
+	admin.do("SET", "secret", "v")
+	admin.do("RPUSH", "list", "v")
+	admin.do("ACL", "SETUSER", "writer", "on", "nopass", "+@all", "%W~*")
+	admin.do("ACL", "SETUSER", "copier", "on", "nopass", "+@all", "%R~src*", "%W~dst*")
+
+	writer := dial(t, srv.Addr())
+	writer.do("AUTH", "writer", "x")
+	for _, argv := range [][]string{
+		{"GET", "secret"},
+		{"COPY", "secret", "dst"},
+		{"RENAME", "secret", "dst"},
+		{"RENAMENX", "secret", "dst"},
+		{"MOVE", "secret", "1"},
+		{"LPOP", "list"},
+		{"RPOP", "list"},
+	} {
+		if reply := writer.do(argv...); !strings.HasPrefix(reply.Str, "NOPERM") {
+			t.Errorf("a write-only user ran %q: %+v", argv, reply)
+		}
+	}
+	if reply := writer.do("SET", "other", "v"); reply.Str != "OK" {
+		t.Errorf("a write-only user could not SET: %+v", reply)
+	}
+
+	copier := dial(t, srv.Addr())
+	copier.do("AUTH", "copier", "x")
+	admin.do("SET", "src", "v")
+	if reply := copier.do("COPY", "src", "dst"); reply.Int != 1 {
+		t.Errorf("COPY from a readable to a writable key replied %+v", reply)
+	}
+	if reply := copier.do("COPY", "dst", "src"); !strings.HasPrefix(reply.Str, "NOPERM") {
+		t.Errorf("COPY from a write-only key replied %+v", reply)
+	}
+	if reply := copier.do("RENAME", "src", "dst"); !strings.HasPrefix(reply.Str, "NOPERM") {
+		t.Errorf("RENAME of a read-only key replied %+v", reply)
+	}
+}
@@ -0,0 +1,117 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func closed(c *testConn) bool {
+	c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, err := c.reader.ReadByte()
+	return err == io.EOF
+}
+
+func TestClientKill(t *testing.T) {
+	srv := startServer(t)
+	admin, byID, byAddr, byUser := dial(t, srv.Addr()), dial(t, srv.Addr()), dial(t, srv.Addr()), dial(t, srv.Addr())
+
+	id := byID.do("CLIENT", "ID").Int
+	if reply := admin.do("CLIENT", "KILL", "ID", fmt.Sprint(id)); reply.Int != 1 {
+		t.Errorf("CLIENT KILL ID replied %+v", reply)
+	}
+	if !closed(byID) {
+		t.Error("CLIENT KILL ID left the connection open")
+	}
+
+	addr := byAddr.conn.LocalAddr().String()
+	if reply := admin.do("CLIENT", "KILL", addr); reply.Str != "OK" {
+		t.Errorf("CLIENT KILL addr replied %+v", reply)
+	}
+	if !closed(byAddr) {
+		t.Error("CLIENT KILL addr left the connection open")
+	}
+	if reply := admin.do("CLIENT", "KILL", addr); reply.Str != "ERR No such client" {
+		t.Errorf("CLIENT KILL of a gone addr replied %+v", reply)
+	}
+
+	// SKIPME defaults to yes, so the caller survives killing its own user.
+	if reply := admin.do("CLIENT", "KILL", "USER", "default"); reply.Int != 1 {
+		t.Errorf("CLIENT KILL USER replied %+v", reply)
+	}
+	if !closed(byUser) {
+		t.Error("CLIENT KILL USER left the connection open")
+	}
+	if list := admin.do("CLIENT", "LIST").Str; strings.Count(list, "\n") != 1 {
+		t.Errorf("CLIENT LIST after kills = %q", list)
+	}
+
+	if reply := admin.do("CLIENT", "KILL", "ID", "0"); !strings.HasPrefix(reply.Str, "ERR") {
+		t.Errorf("CLIENT KILL ID 0 replied %+v", reply)
+	}
+	if reply := admin.do("CLIENT", "KILL", "ADDR", admin.conn.LocalAddr().String(), "SKIPME", "no"); reply.Int != 1 {
+		t.Errorf("CLIENT KILL SKIPME no replied %+v", reply)
+	}
+	if !closed(admin) {
+		t.Error("CLIENT KILL SKIPME no did not close the caller after replying")
+	}
+}
+
+func TestClientReply(t *testing.T) {
+	srv := startServer(t)
+	c := dial(t, srv.Addr())
+
+	c.send("CLIENT", "REPLY", "SKIP")
+	c.send("SET", "k", "1")
+	if reply := c.do("GET", "k"); reply.Str != "1" {
+		t.Fatalf("after REPLY SKIP, the first reply read is %+v, want GET's", reply)
+	}
+
+	c.send("CLIENT", "REPLY", "OFF")
+	c.send("SET", "k", "2")
+	c.send("GET", "k")
+	if reply := c.do("CLIENT", "REPLY", "ON"); reply.Str != "OK" {
+		t.Fatalf("after REPLY OFF, the first reply read is %+v, want REPLY ON's", reply)
+	}
+	if reply := c.do("GET", "k"); reply.Str != "2" {
+		t.Errorf("GET after REPLY ON = %+v", reply)
+	}
+}
+
+func TestClientPauseWrite(t *testing.T) {
+	srv := startServer(t)
+	admin, c := dial(t, srv.Addr()), dial(t, srv.Addr())
+
+	if reply := admin.do("CLIENT", "PAUSE", "10000", "WRITE"); reply.Str != "OK" {
+		t.Fatalf("CLIENT PAUSE replied %+v", reply)
+	}
+	if reply := c.do("GET", "k"); !reply.IsNull {
+		t.Errorf("GET during a WRITE pause replied %+v", reply)
+	}
+	c.send("SET", "k", "v")
+	time.Sleep(50 * time.Millisecond)
+	if reply := admin.do("GET", "k"); !reply.IsNull {
+		t.Fatalf("SET ran during the pause: %+v", reply)
+	}
+	admin.do("CLIENT", "UNPAUSE")
+	c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if reply := c.read(); reply.Str != "OK" {
+		t.Errorf("SET after UNPAUSE replied %+v", reply)
+	}
+}
+
+func TestClientListRecordsNoUnknownCommandName(t *testing.T) {
+	srv := startServer(t)
+	admin, c := dial(t, srv.Addr()), dial(t, srv.Addr())
+
+	id := c.do("CLIENT", "ID").Int
+	if reply := c.do("bad name\nid=0 cmd=forged"); !strings.HasPrefix(reply.Str, "ERR unknown command") {
+		t.Fatalf("unknown command replied %+v", reply)
+	}
+	list := admin.do("CLIENT", "LIST", "ID", fmt.Sprint(id)).Str
+	if strings.Count(list, "\n") != 1 || !strings.Contains(list, " cmd=NULL ") {
+		t.Errorf("CLIENT LIST after an unknown command = %q", list)
+	}
+}
@@ -0,0 +1,257 @@
+package server
+
+import (
+	"fmt"
+	"mini-redis/acl"
+	"mini-redis/protocol"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// checkPermissions runs the ACL checks for the calling user before a
+// command is dispatched, logging and returning the error reply on denial.
+func checkPermissions(c *client, cmd *command, argv []string) string {
+	name := c.username()
+	user := c.srv.acl.User(name)
+	if user == nil {
+		return fmt.Sprintf("NOPERM User %s has no permissions to run the '%s' command", name, cmd.fullName())
+	}
+	if !cmd.has(flagNoAuth) && !user.CanRun(cmd.fullName(), cmd.categories()) {
+		c.srv.aclLog.Add("command", "toplevel", cmd.fullName(), name, c.info(), c.srv.config.Current().ACLLogMaxLen)
+		return fmt.Sprintf("NOPERM User %s has no permissions to run the '%s' command", name, cmd.fullName())
+	}
+	for i, key := range cmd.keys(argv) {
+		access := cmd.keyAccess(i)
+		if (access&keyRead != 0 && !user.CanAccessKey(key, false)) || (access&keyWrite != 0 && !user.CanAccessKey(key, true)) {
+			c.srv.aclLog.Add("key", "toplevel", key, name, c.info(), c.srv.config.Current().ACLLogMaxLen)
+			return "NOPERM No permissions to access a key"
+		}
+	}
+	return ""
+}
+
+// authenticate switches the client to username if the password matches,
+// recording failed attempts in the ACL log.
+func (c *client) authenticate(username, password string) bool {
+	if c.srv.acl.Authenticate(username, password) == nil {
+		c.srv.aclLog.Add("auth", "toplevel", "AUTH", username, c.info(), c.srv.config.Current().ACLLogMaxLen)
+		return false
+	}
+	c.setUser(username)
+	return true
+}
+
+func authCommand(c *client, args []string) {
+	if len(args) > 2 {
+		c.w.WriteError("ERR syntax error")
+		return
+	}
+	username, password := acl.DefaultUser, args[0]
+	if len(args) == 2 {
+		username, password = args[0], args[1]
+	} else if u := c.srv.acl.User(acl.DefaultUser); u != nil && u.NoPass() {
+		c.w.WriteError("ERR AUTH <password> called without any password configured for the default user. Are you sure your configuration is correct?")
+		return
+	}
+	if !c.authenticate(username, password) {
+		c.w.WriteError("WRONGPASS invalid username-password pair or user is disabled.")
+		return
+	}
+	c.w.WriteSimpleString("OK")
+}
+
+func aclCatCommand(c *client, args []string) {
+	if len(args) == 0 {
+		c.w.WriteValue(protocol.NewStringArray(acl.Categories))
+		return
+	}
+	if len(args) > 1 {
+		c.w.WriteError("ERR wrong number of arguments for 'ACL|CAT' command")
+		return
+	}
+
+	category := strings.ToLower(args[0])
+	known := false
+	for _, name := range acl.Categories {
+		known = known || name == category
+	}
+	if !known {
+		c.w.WriteError(fmt.Sprintf("ERR Unknown category '%s'", args[0]))
+		return
+	}
+	names := []string{}
+	for _, cmd := range sortedCommands(commandTable) {
+		commands := []*command{cmd}
+		if cmd.subcommands != nil {
+			commands = sortedCommands(cmd.subcommands)
+		}
+		for _, cmd := range commands {
+			for _, name := range cmd.categories() {
+				if name == category {
+					names = append(names, cmd.fullName())
+					break
+				}
+			}
+		}
+	}
+	c.w.WriteValue(protocol.NewStringArray(names))
+}
+
+func aclSetUserCommand(c *client, args []string) {
+	if err := c.srv.acl.SetUser(args[0], args[1:]...); err != nil {
+		c.w.WriteError("ERR " + err.Error())
+		return
+	}
+	c.w.WriteSimpleString("OK")
+}
+
+func aclGetUserCommand(c *client, args []string) {
+	user := c.srv.acl.User(args[0])
+	if user == nil {
+		c.w.WriteNull()
+		return
+	}
+	c.w.WriteValue(protocol.NewMap(
+		protocol.NewBulkString("flags"), protocol.NewStringArray(user.Flags()),
+		protocol.NewBulkString("passwords"), protocol.NewStringArray(user.Passwords()),
+		protocol.NewBulkString("commands"), protocol.NewBulkString(user.Commands()),
+		protocol.NewBulkString("keys"), protocol.NewBulkString(user.Keys()),
+	))
+}
+
+func aclDelUserCommand(c *client, args []string) {
+	deleted, err := c.srv.acl.DelUser(args...)
+	if err != nil {
+		c.w.WriteError("ERR " + err.Error())
+		return
+	}
+	for _, name := range args {
+		killClients(c, &clientFilter{user: name})
+	}
+	c.w.WriteInteger(int64(deleted))
+}
+
+func aclListCommand(c *client, args []string) {
+	lines := []string{}
+	for _, user := range c.srv.acl.Users() {
+		lines = append(lines, user.Describe())
+	}
+	c.w.WriteValue(protocol.NewStringArray(lines))
+}
+
+func aclUsersCommand(c *client, args []string) {
+	names := []string{}
+	for _, user := range c.srv.acl.Users() {
+		names = append(names, user.Name())
+	}
+	c.w.WriteValue(protocol.NewStringArray(names))
+}
+
+func aclWhoAmICommand(c *client, args []string) {
+	c.w.WriteBulkString(c.username())
+}
+
+const errNoACLFile = "ERR This Redis instance is not configured to use an ACL file. You may want to specify users via the ACL SETUSER command and then issue a CONFIG REWRITE (assuming you have a Redis configuration file set) in order to store users in the Redis configuration."
+
+func aclLoadCommand(c *client, args []string) {
+	path := c.srv.config.Current().ACLFile
+	if path == "" {
+		c.w.WriteError(errNoACLFile)
+		return
+	}
+	if err := c.srv.acl.LoadFile(path); err != nil {
+		c.w.WriteError("ERR " + err.Error())
+		return
+	}
+	// Connections authenticated as users the file no longer defines are
+	// dropped, like after ACL DELUSER.
+	for _, other := range c.srv.clientList() {
+		if name := other.username(); c.srv.acl.User(name) == nil {
+			killClients(c, &clientFilter{user: name})
+		}
+	}
+	c.w.WriteSimpleString("OK")
+}
+
+func aclSaveCommand(c *client, args []string) {
+	path := c.srv.config.Current().ACLFile
+	if path == "" {
+		c.w.WriteError(errNoACLFile)
+		return
+	}
+	if err := c.srv.acl.SaveFile(path); err != nil {
+		c.srv.log.Warning("Error saving ACL file", "path", path, "err", err)
+		c.w.WriteError("ERR There was an error trying to save the ACLs. Please check the server logs for more information")
+		return
+	}
+	c.w.WriteSimpleString("OK")
+}
+
+func aclLogCommand(c *client, args []string) {
+	count := 10
+	if len(args) > 1 {
+		c.w.WriteError("ERR wrong number of arguments for 'ACL|LOG' command")
+		return
+	}
+	if len(args) == 1 {
+		if strings.EqualFold(args[0], "RESET") {
+			c.srv.aclLog.Reset()
+			c.w.WriteSimpleString("OK")
+			return
+		}
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < 0 {
+			c.w.WriteError("ERR value is out of range, must be positive")
+			return
+		}
+		count = n
+	}
+
+	now := time.Now()
+	entries := c.srv.aclLog.Entries(count)
+	c.w.WriteArrayHeader(len(entries))
+	for _, entry := range entries {
+		c.w.WriteValue(protocol.NewMap(
+			protocol.NewBulkString("count"), protocol.NewInteger(int64(entry.Count)),
+			protocol.NewBulkString("reason"), protocol.NewBulkString(entry.Reason),
+			protocol.NewBulkString("context"), protocol.NewBulkString(entry.Context),
+			protocol.NewBulkString("object"), protocol.NewBulkString(entry.Object),
+			protocol.NewBulkString("username"), protocol.NewBulkString(entry.Username),
+			protocol.NewBulkString("age-seconds"), protocol.NewDouble(now.Sub(entry.Created).Seconds()),
+			protocol.NewBulkString("client-info"), protocol.NewBulkString(entry.ClientInfo),
+			protocol.NewBulkString("entry-id"), protocol.NewInteger(entry.EntryID),
+			protocol.NewBulkString("timestamp-created"), protocol.NewInteger(entry.Created.UnixMilli()),
+			protocol.NewBulkString("timestamp-last-updated"), protocol.NewInteger(entry.Updated.UnixMilli()),
+		))
+	}
+}
+
+func aclHelpCommand(c *client, args []string) {
+	c.w.WriteValue(protocol.NewStringArray([]string{
+		"ACL <subcommand> [<arg> [value] [opt] ...]. Subcommands are:",
+		"CAT [<category>]",
+		"    List all commands that belong to <category>, or all command categories",
+		"    when no category is specified.",
+		"DELUSER <username> [<username> ...]",
+		"    Delete a list of users.",
+		"GETUSER <username>",
+		"    Get the user's details.",
+		"LIST",
+		"    Show users details in config file format.",
+		"LOAD",
+		"    Reload users from the ACL file.",
+		"LOG [<count> | RESET]",
+		"    Show the ACL log entries.",
+		"SAVE",
+		"    Save the current config to the ACL file.",
+		"SETUSER <username> <attribute> [<attribute> ...]",
+		"    Create or modify a user with the specified attributes.",
+		"USERS",
+		"    List all the registered usernames.",
+		"WHOAMI",
+		"    Return the current connection username.",
+		"HELP",
+		"    Print this help.",
+	}))
+}
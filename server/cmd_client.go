@@ -0,0 +1,286 @@
+package server
+
+import (
+	"fmt"
+	"mini-redis/protocol"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func clientIDCommand(c *client, args []string) {
+	c.w.WriteInteger(c.id)
+}
+
+func clientInfoCommand(c *client, args []string) {
+	c.w.WriteValue(protocol.NewVerbatim("txt", c.info()+"\n"))
+}
+
+func clientListCommand(c *client, args []string) {
+	kind := ""
+	var ids map[int64]bool
+	for i := 0; i < len(args); i++ {
+		switch option := strings.ToUpper(args[i]); {
+		case option == "TYPE" && i+1 < len(args):
+			kind = strings.ToLower(args[i+1])
+			if !validClientType(kind) {
+				c.w.WriteError(fmt.Sprintf("ERR Unknown client type '%s'", args[i+1]))
+				return
+			}
+			i++
+		case option == "ID" && i+1 < len(args):
+			ids = map[int64]bool{}
+			for i++; i < len(args); i++ {
+				id, err := strconv.ParseInt(args[i], 10, 64)
+				if err != nil || id <= 0 {
+					c.w.WriteError("ERR Invalid client ID")
+					return
+				}
+				ids[id] = true
+			}
+		default:
+			c.w.WriteError("ERR syntax error")
+			return
+		}
+	}
+
+	var sb strings.Builder
+	for _, other := range c.srv.clientList() {
+		if (kind != "" && other.kind() != kind) || (ids != nil && !ids[other.id]) {
+			continue
+		}
+		sb.WriteString(other.info())
+		sb.WriteByte('\n')
+	}
+	c.w.WriteValue(protocol.NewVerbatim("txt", sb.String()))
+}
+
+func validClientType(kind string) bool {
+	switch kind {
+	case "normal", "master", "replica", "slave", "pubsub":
+		return true
+	}
+	return false
+}
+
+type clientFilter struct {
+	id     int64
+	addr   string
+	laddr  string
+	kind   string
+	user   string
+	maxAge time.Duration
+	skipMe bool
+}
+
+func (f *clientFilter) matches(self, other *client) bool {
+	switch {
+	case f.skipMe && other == self:
+		return false
+	case f.id != 0 && other.id != f.id:
+		return false
+	case f.addr != "" && other.addr() != f.addr:
+		return false
+	case f.laddr != "" && other.laddr() != f.laddr:
+		return false
+	case f.kind != "" && other.kind() != f.kind:
+		return false
+	case f.user != "" && other.username() != f.user:
+		return false
+	case f.maxAge != 0 && other.age() < f.maxAge:
+		return false
+	}
+	return true
+}
+
+func clientKillCommand(c *client, args []string) {
+	// The original form takes a single ip:port and replies +OK.
+	if len(args) == 1 {
+		filter := &clientFilter{addr: args[0]}
+		if killClients(c, filter) == 0 {
+			c.w.WriteError("ERR No such client")
+			return
+		}
+		c.w.WriteSimpleString("OK")
+		return
+	}
+	if len(args)%2 != 0 {
+		c.w.WriteError("ERR syntax error")
+		return
+	}
+
+	filter := &clientFilter{skipMe: true}
+	for i := 0; i < len(args); i += 2 {
+		value := args[i+1]
+		switch strings.ToUpper(args[i]) {
+		case "ID":
+			id, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || id <= 0 {
+				c.w.WriteError("ERR client-id should be greater than 0")
+				return
+			}
+			filter.id = id
+		case "ADDR":
+			filter.addr = value
+		case "LADDR":
+			filter.laddr = value
+		case "TYPE":
+			filter.kind = strings.ToLower(value)
+			if !validClientType(filter.kind) {
+				c.w.WriteError(fmt.Sprintf("ERR Unknown client type '%s'", value))
+				return
+			}
+		case "USER":
+			filter.user = value
+		case "SKIPME":
+			switch strings.ToLower(value) {
+			case "yes":
+				filter.skipMe = true
+			case "no":
+				filter.skipMe = false
+			default:
+				c.w.WriteError("ERR syntax error")
+				return
+			}
+		case "MAXAGE":
+			seconds, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || seconds <= 0 {
+				c.w.WriteError("ERR syntax error")
+				return
+			}
+			filter.maxAge = time.Duration(seconds) * time.Second
+		default:
+			c.w.WriteError("ERR syntax error")
+			return
+		}
+	}
+	c.w.WriteInteger(int64(killClients(c, filter)))
+}
+
+// killClients closes every matching connection. The calling client is only
+// marked, so that it still receives the reply before being disconnected.
+func killClients(self *client, filter *clientFilter) int {
+	killed := 0
+	for _, other := range self.srv.clientList() {
+		if !filter.matches(self, other) {
+			continue
+		}
+		if other == self {
+			self.closeAfterReply = true
+		} else {
+			other.conn.Close()
+		}
+		killed++
+	}
+	return killed
+}
+
+func clientSetNameCommand(c *client, args []string) {
+	if !validClientName(args[0]) {
+		c.w.WriteError("ERR Client names cannot contain spaces, newlines or special characters.")
+		return
+	}
+	c.setName(args[0])
+	c.w.WriteSimpleString("OK")
+}
+
+func clientGetNameCommand(c *client, args []string) {
+	name := c.getName()
+	if name == "" {
+		c.w.WriteNull()
+		return
+	}
+	c.w.WriteBulkString(name)
+}
+
+func clientPauseCommand(c *client, args []string) {
+	timeout, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil || timeout < 0 {
+		c.w.WriteError("ERR timeout is not an integer or out of range")
+		return
+	}
+	all := true
+	if len(args) == 2 {
+		switch strings.ToUpper(args[1]) {
+		case "ALL":
+		case "WRITE":
+			all = false
+		default:
+			c.w.WriteError("ERR syntax error")
+			return
+		}
+	} else if len(args) > 2 {
+		c.w.WriteError("ERR syntax error")
+		return
+	}
+	c.srv.pauseClients(time.Duration(timeout)*time.Millisecond, all)
+	c.w.WriteSimpleString("OK")
+}
+
+func clientUnpauseCommand(c *client, args []string) {
+	c.srv.unpause(nil)
+	c.w.WriteSimpleString("OK")
+}
+
+func clientReplyCommand(c *client, args []string) {
+	switch strings.ToUpper(args[0]) {
+	case "ON":
+		c.replyMode = replyOn
+		c.w.SetDiscard(false)
+		c.w.WriteSimpleString("OK")
+	case "OFF":
+		c.replyMode = replyOff
+	case "SKIP":
+		if c.replyMode == replyOn {
+			c.replyMode = replySkip
+		}
+	default:
+		c.w.WriteError("ERR syntax error")
+	}
+}
+
+func clientHelpCommand(c *client, args []string) {
+	c.w.WriteValue(protocol.NewStringArray([]string{
+		"CLIENT <subcommand> [<arg> [value] [opt] ...]. Subcommands are:",
+		"GETNAME",
+		"    Return the name of the current connection.",
+		"ID",
+		"    Return the ID of the current connection.",
+		"INFO",
+		"    Return information about the current client connection.",
+		"KILL <ip:port>",
+		"    Kill connection made from <ip:port>.",
+		"KILL <option> <value> [<option> <value> [...]]",
+		"    Kill connections. Options are:",
+		"    * ADDR (<ip:port>|<unixsocket>:0)",
+		"      Kill connections made from the specified address",
+		"    * LADDR (<ip:port>|<unixsocket>:0)",
+		"      Kill connections made to specified local address",
+		"    * TYPE (NORMAL|MASTER|REPLICA|PUBSUB)",
+		"      Kill connections by type.",
+		"    * USER <username>",
+		"      Kill connections authenticated by <username>.",
+		"    * SKIPME (YES|NO)",
+		"      Skip killing current connection (default: yes).",
+		"    * ID <client-id>",
+		"      Kill connections by client id.",
+		"    * MAXAGE <maxage>",
+		"      Kill connections older than the specified age.",
+		"LIST [options ...]",
+		"    Return information about client connections. Options:",
+		"    * TYPE (NORMAL|MASTER|REPLICA|PUBSUB)",
+		"      Return clients of specified type.",
+		"    * ID <client-id> [<client-id> ...]",
+		"      Return clients of specified IDs only.",
+		"PAUSE <timeout> [WRITE|ALL]",
+		"    Suspend all, or just write, clients for <timeout> milliseconds.",
+		"UNPAUSE",
+		"    Stop the current client pause, resuming traffic.",
+		"REPLY (ON|OFF|SKIP)",
+		"    Control the replies sent to the current connection.",
+		"SETNAME <name>",
+		"    Assign the name <name> to the current connection.",
+		"HELP",
+		"    Print this help.",
+	}))
+}
@@ -0,0 +1,57 @@
+package server
+
+import "mini-redis/protocol"
+
+func configGetCommand(c *client, args []string) {
+	pairs := c.srv.config.Get(args...)
+	c.w.WriteMapHeader(len(pairs))
+	for _, pair := range pairs {
+		c.w.WriteBulkString(pair[0])
+		c.w.WriteBulkString(pair[1])
+	}
+}
+
+func configSetCommand(c *client, args []string) {
+	if len(args)%2 != 0 {
+		c.w.WriteError("ERR wrong number of arguments for 'CONFIG|SET' command")
+		return
+	}
+	pairs := make([][2]string, 0, len(args)/2)
+	for i := 0; i < len(args); i += 2 {
+		pairs = append(pairs, [2]string{args[i], args[i+1]})
+	}
+	if err := c.srv.config.Set(pairs...); err != nil {
+		c.w.WriteError("ERR " + err.Error())
+		return
+	}
+	c.w.WriteSimpleString("OK")
+}
+
+func configRewriteCommand(c *client, args []string) {
+	if err := c.srv.config.Rewrite(); err != nil {
+		c.w.WriteError("ERR Rewriting config file: " + err.Error())
+		return
+	}
+	c.w.WriteSimpleString("OK")
+}
+
+func configResetStatCommand(c *client, args []string) {
+	c.srv.resetStats()
+	c.w.WriteSimpleString("OK")
+}
+
+func configHelpCommand(c *client, args []string) {
+	c.w.WriteValue(protocol.NewStringArray([]string{
+		"CONFIG <subcommand> [<arg> [value] [opt] ...]. Subcommands are:",
+		"GET <pattern>",
+		"    Return parameters matching the glob-like <pattern> and their values.",
+		"SET <directive> <value>",
+		"    Set the configuration <directive> to <value>.",
+		"RESETSTAT",
+		"    Reset statistics reported by the INFO command.",
+		"REWRITE",
+		"    Rewrite the configuration file.",
+		"HELP",
+		"    Print this help.",
+	}))
+}
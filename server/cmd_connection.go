@@ -0,0 +1,105 @@
+package server
+
+import (
+	"fmt"
+	"mini-redis/acl"
+	"mini-redis/protocol"
+	"strconv"
+	"strings"
+)
+
+func helloCommand(c *client, args []string) {
+	proto := c.w.Protocol()
+	if len(args) > 0 {
+		version, err := strconv.Atoi(args[0])
+		if err != nil {
+			c.w.WriteError("ERR Protocol version is not an integer or out of range")
+			return
+		}
+		if version != protocol.RESP2 && version != protocol.RESP3 {
+			c.w.WriteError("NOPROTO unsupported protocol version")
+			return
+		}
+		proto = version
+	}
+
+	name := c.getName()
+	var auth []string
+	for i := 1; i < len(args); i++ {
+		option := strings.ToUpper(args[i])
+		switch {
+		case option == "AUTH" && i+2 < len(args):
+			auth = args[i+1 : i+3]
+			i += 2
+		case option == "SETNAME" && i+1 < len(args):
+			if !validClientName(args[i+1]) {
+				c.w.WriteError("ERR Client names cannot contain spaces, newlines or special characters.")
+				return
+			}
+			name = args[i+1]
+			i++
+		default:
+			c.w.WriteError(fmt.Sprintf("ERR Syntax error in HELLO option '%s'", args[i]))
+			return
+		}
+	}
+
+	if auth != nil && !c.authenticate(auth[0], auth[1]) {
+		c.w.WriteError("WRONGPASS invalid username-password pair or user is disabled.")
+		return
+	}
+	if !c.isAuthenticated() {
+		c.w.WriteError("NOAUTH HELLO must be called with the client already authenticated, otherwise the HELLO <proto> AUTH <user> <pass> option can be used to authenticate the client and select the RESP protocol version at the same time")
+		return
+	}
+
+	c.setName(name)
+	c.setProtocol(proto)
+
+	c.w.WriteValue(protocol.NewMap(
+		protocol.NewBulkString("server"), protocol.NewBulkString(serverName),
+		protocol.NewBulkString("version"), protocol.NewBulkString(serverVersion),
+		protocol.NewBulkString("proto"), protocol.NewInteger(int64(proto)),
+		protocol.NewBulkString("id"), protocol.NewInteger(c.id),
+		protocol.NewBulkString("mode"), protocol.NewBulkString("standalone"),
+		protocol.NewBulkString("role"), protocol.NewBulkString("master"),
+		protocol.NewBulkString("modules"), protocol.NewArray(),
+	))
+}
+
+func quitCommand(c *client, args []string) {
+	c.w.WriteSimpleString("OK")
+	c.closeAfterReply = true
+}
+
+// resetCommand returns the connection to the state of a new one: out of
+// MONITOR mode, replies on, RESP2, no name, database 0 and authenticated as the
+// default user only if that user needs no password.
+func resetCommand(c *client, args []string) {
+	c.stopMonitor()
+	c.replyMode = replyOn
+	c.w.SetDiscard(false)
+	c.setProtocol(protocol.RESP2)
+	c.setName("")
+	c.selectDB(0)
+
+	authenticated := false
+	if u := c.srv.acl.User(acl.DefaultUser); u != nil && u.Enabled() && u.NoPass() {
+		authenticated = true
+	}
+	c.mu.Lock()
+	c.user, c.authenticated = acl.DefaultUser, authenticated
+	c.mu.Unlock()
+
+	c.w.WriteSimpleString("RESET")
+}
+
+func selectCommand(c *client, args []string) {
+	index, errReply := c.srv.parseDBIndex(args[0], "ERR value is not an integer or out of range")
+	if errReply != "" {
+		c.w.WriteError(errReply)
+		return
+	}
+	c.selectDB(index)
+	c.w.WriteSimpleString("OK")
+}
@@ -0,0 +1,157 @@
+package server
+
+import (
+	"math"
+	"mini-redis/store"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func expireCommand(c *client, args []string) {
+	expireGeneric(c, args, "expire", time.Second, false)
+}
+
+func pexpireCommand(c *client, args []string) {
+	expireGeneric(c, args, "pexpire", time.Millisecond, false)
+}
+
+func expireatCommand(c *client, args []string) {
+	expireGeneric(c, args, "expireat", time.Second, true)
+}
+
+func pexpireatCommand(c *client, args []string) {
+	expireGeneric(c, args, "pexpireat", time.Millisecond, true)
+}
+
+// expireGeneric implements the EXPIRE family. The time argument is in
+// unit, and relative to now unless absolute is set.
+func expireGeneric(c *client, args []string, name string, unit time.Duration, absolute bool) {
+	value, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		c.w.WriteError("ERR value is not an integer or out of range")
+		return
+	}
+	cond, errReply := parseExpireCondition(args[2:])
+	if errReply != "" {
+		c.w.WriteError(errReply)
+		return
+	}
+
+	ms, ok := value, true
+	if unit == time.Second {
+		ms, ok = multiplyMs(value)
+	}
+	if ok && !absolute {
+		ms, ok = addMs(ms, time.Now().UnixMilli())
+	}
+	if !ok {
+		c.w.WriteError("ERR invalid expire time in '" + name + "' command")
+		return
+	}
+
+	if c.db.Expire(args[0], time.UnixMilli(ms), cond) {
+		c.w.WriteInteger(1)
+	} else {
+		c.w.WriteInteger(0)
+	}
+}
+
+func parseExpireCondition(args []string) (store.ExpireCondition, string) {
+	var cond store.ExpireCondition
+	for _, arg := range args {
+		switch strings.ToUpper(arg) {
+		case "NX":
+			cond |= store.ExpireNX
+		case "XX":
+			cond |= store.ExpireXX
+		case "GT":
+			cond |= store.ExpireGT
+		case "LT":
+			cond |= store.ExpireLT
+		default:
+			return 0, "ERR Unsupported option " + arg
+		}
+	}
+	if cond&store.ExpireNX != 0 && cond != store.ExpireNX {
+		return 0, "ERR NX and XX, GT or LT options at the same time are not compatible"
+	}
+	if cond&store.ExpireGT != 0 && cond&store.ExpireLT != 0 {
+		return 0, "ERR GT and LT options at the same time are not compatible"
+	}
+	return cond, ""
+}
+
+func multiplyMs(seconds int64) (int64, bool) {
+	if seconds > math.MaxInt64/1000 || seconds < math.MinInt64/1000 {
+		return 0, false
+	}
+	return seconds * 1000, true
+}
+
+func addMs(a, b int64) (int64, bool) {
+	if (b > 0 && a > math.MaxInt64-b) || (b < 0 && a < math.MinInt64-b) {
+		return 0, false
+	}
+	return a + b, true
+}
+
+func ttlCommand(c *client, args []string) {
+	ttlGeneric(c, args[0], func(at time.Time) int64 {
+		return roundToSeconds(remainingMs(at))
+	})
+}
+
+func pttlCommand(c *client, args []string) {
+	ttlGeneric(c, args[0], remainingMs)
+}
+
+func expiretimeCommand(c *client, args []string) {
+	ttlGeneric(c, args[0], func(at time.Time) int64 {
+		return roundToSeconds(at.UnixMilli())
+	})
+}
+
+func pexpiretimeCommand(c *client, args []string) {
+	ttlGeneric(c, args[0], func(at time.Time) int64 {
+		return at.UnixMilli()
+	})
+}
+
+// remainingMs returns the milliseconds left until at. Unlike time.Until,
+// it does not saturate at the ~292 years a time.Duration can hold.
+func remainingMs(at time.Time) int64 {
+	ms, ok := addMs(at.UnixMilli(), -time.Now().UnixMilli())
+	if !ok {
+		return 0
+	}
+	return ms
+}
+
+// roundToSeconds rounds a non-negative ms to the nearest second without
+// overflowing.
+func roundToSeconds(ms int64) int64 {
+	return ms/1000 + (ms%1000+500)/1000
+}
+
+// ttlGeneric replies with format applied to the expiry of key, -1 if key
+// has no TTL or -2 if it does not exist.
+func ttlGeneric(c *client, key string, format func(at time.Time) int64) {
+	at, expiring, exists := c.db.ExpireTime(key)
+	switch {
+	case !exists:
+		c.w.WriteInteger(-2)
+	case !expiring:
+		c.w.WriteInteger(-1)
+	default:
+		c.w.WriteInteger(max(format(at), 0))
+	}
+}
+
+func persistCommand(c *client, args []string) {
+	if c.db.Persist(args[0]) {
+		c.w.WriteInteger(1)
+	} else {
+		c.w.WriteInteger(0)
+	}
+}
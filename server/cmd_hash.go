@@ -0,0 +1,37 @@
+package server
+
+func hsetCommand(c *client, args []string) {
+	added, err := c.db.HSet(args[0], args[1], args[2])
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return
+	}
+	c.w.WriteInteger(int64(added))
+}
+
+func hgetCommand(c *client, args []string) {
+	value, exists, err := c.db.HGet(args[0], args[1])
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return
+	}
+	if !exists {
+		c.w.WriteNull()
+		return
+	}
+	c.w.WriteBulkString(value)
+}
+
+func hscanCommand(c *client, args []string) {
+	parsed, errReply := parseScanArgs(args[1:], false)
+	if errReply != "" {
+		c.w.WriteError(errReply)
+		return
+	}
+	pairs, next, err := c.db.HScan(args[0], parsed.cursor, parsed.count, parsed.pattern)
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return
+	}
+	writeScanReply(c, next, pairs)
+}
@@ -0,0 +1,176 @@
+package server
+
+import (
+	"fmt"
+	"mini-redis/protocol"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+type infoSection struct {
+	name string
+	// byDefault sections are included by a plain INFO.
+	byDefault bool
+	render    func(s *Server, sb *strings.Builder)
+}
+
+var infoSections = []infoSection{
+	{"server", true, (*Server).infoServer},
+	{"clients", true, (*Server).infoClients},
+	{"memory", true, (*Server).infoMemory},
+	{"persistence", true, (*Server).infoPersistence},
+	{"stats", true, (*Server).infoStats},
+	{"commandstats", false, (*Server).infoCommandStats},
+	{"keyspace", true, (*Server).infoKeyspace},
+}
+
+func infoCommand(c *client, args []string) {
+	all, everything := len(args) == 0, false
+	requested := map[string]bool{}
+	for _, arg := range args {
+		switch name := strings.ToLower(arg); name {
+		case "default":
+			all = true
+		case "all", "everything":
+			everything = true
+		default:
+			requested[name] = true
+		}
+	}
+
+	var sb strings.Builder
+	for _, section := range infoSections {
+		if !everything && !requested[section.name] && !(all && section.byDefault) {
+			continue
+		}
+		if sb.Len() > 0 {
+			sb.WriteString("\r\n")
+		}
+		fmt.Fprintf(&sb, "# %s\r\n", strings.ToUpper(section.name[:1])+section.name[1:])
+		section.render(c.srv, &sb)
+	}
+	c.w.WriteValue(protocol.NewVerbatim("txt", sb.String()))
+}
+
+func (s *Server) infoServer(sb *strings.Builder) {
+	cfg := s.config.Current()
+	executable, _ := os.Executable()
+	uptime := time.Since(s.startTime)
+	fmt.Fprintf(sb, "redis_version:%s\r\n", serverVersion)
+	fmt.Fprintf(sb, "redis_mode:standalone\r\n")
+	fmt.Fprintf(sb, "os:%s %s\r\n", runtime.GOOS, runtime.GOARCH)
+	fmt.Fprintf(sb, "arch_bits:%d\r\n", strconv.IntSize)
+	fmt.Fprintf(sb, "go_version:%s\r\n", runtime.Version())
+	fmt.Fprintf(sb, "process_id:%d\r\n", os.Getpid())
+	fmt.Fprintf(sb, "run_id:%s\r\n", s.runID)
+	fmt.Fprintf(sb, "tcp_port:%d\r\n", cfg.Port)
+	fmt.Fprintf(sb, "server_time_usec:%d\r\n", time.Now().UnixMicro())
+	fmt.Fprintf(sb, "uptime_in_seconds:%d\r\n", int64(uptime/time.Second))
+	fmt.Fprintf(sb, "uptime_in_days:%d\r\n", int64(uptime/(24*time.Hour)))
+	fmt.Fprintf(sb, "executable:%s\r\n", executable)
+	fmt.Fprintf(sb, "config_file:%s\r\n", s.config.Path())
+}
+
+func (s *Server) infoClients(sb *strings.Builder) {
+	s.mu.Lock()
+	connected := len(s.clients)
+	s.mu.Unlock()
+	fmt.Fprintf(sb, "connected_clients:%d\r\n", connected)
+}
+
+func (s *Server) infoMemory(sb *strings.Builder) {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	fmt.Fprintf(sb, "used_memory:%d\r\n", mem.HeapAlloc)
+	fmt.Fprintf(sb, "used_memory_human:%s\r\n", humanBytes(mem.HeapAlloc))
+	fmt.Fprintf(sb, "used_memory_rss:%d\r\n", mem.Sys)
+	fmt.Fprintf(sb, "used_memory_rss_human:%s\r\n", humanBytes(mem.Sys))
+	fmt.Fprintf(sb, "total_allocated:%d\r\n", mem.TotalAlloc)
+	fmt.Fprintf(sb, "gc_cycles:%d\r\n", mem.NumGC)
+	fmt.Fprintf(sb, "mem_allocator:go\r\n")
+}
+
+func (s *Server) infoPersistence(sb *strings.Builder) {
+	s.saves.mu.Lock()
+	inProgress, lastSave, lastOK, lastDuration := s.saves.inProgress, s.saves.lastSave, s.saves.lastOK, s.saves.lastDuration
+	s.saves.mu.Unlock()
+	status := "ok"
+	if !lastOK {
+		status = "err"
+	}
+	fmt.Fprintf(sb, "loading:0\r\n")
+	fmt.Fprintf(sb, "rdb_changes_since_last_save:%d\r\n", atomic.LoadInt64(&s.dirty))
+	fmt.Fprintf(sb, "rdb_bgsave_in_progress:%d\r\n", boolInt(inProgress))
+	fmt.Fprintf(sb, "rdb_last_save_time:%d\r\n", unixTime(lastSave))
+	fmt.Fprintf(sb, "rdb_last_bgsave_status:%s\r\n", status)
+	fmt.Fprintf(sb, "rdb_last_bgsave_time_sec:%d\r\n", int64(lastDuration/time.Second))
+}
+
+func (s *Server) infoStats(sb *strings.Builder) {
+	db := s.dbs.Stats()
+	fmt.Fprintf(sb, "total_connections_received:%d\r\n", atomic.LoadInt64(&s.stats.totalConnections))
+	fmt.Fprintf(sb, "total_commands_processed:%d\r\n", atomic.LoadInt64(&s.stats.totalCommands))
+	fmt.Fprintf(sb, "total_net_input_bytes:%d\r\n", atomic.LoadInt64(&s.stats.netInputBytes))
+	fmt.Fprintf(sb, "total_net_output_bytes:%d\r\n", atomic.LoadInt64(&s.stats.netOutputBytes))
+	fmt.Fprintf(sb, "expired_keys:%d\r\n", db.ExpiredKeys)
+	fmt.Fprintf(sb, "evicted_keys:0\r\n")
+	fmt.Fprintf(sb, "keyspace_hits:%d\r\n", db.KeyspaceHits)
+	fmt.Fprintf(sb, "keyspace_misses:%d\r\n", db.KeyspaceMisses)
+}
+
+func (s *Server) infoCommandStats(sb *strings.Builder) {
+	for _, cmd := range sortedCommands(commandTable) {
+		commands := []*command{cmd}
+		if cmd.subcommands != nil {
+			commands = sortedCommands(cmd.subcommands)
+		}
+		for _, cmd := range commands {
+			calls := atomic.LoadInt64(&cmd.stats.calls)
+			rejected := atomic.LoadInt64(&cmd.stats.rejectedCalls)
+			if calls == 0 && rejected == 0 {
+				continue
+			}
+			usec := atomic.LoadInt64(&cmd.stats.usec)
+			perCall := 0.0
+			if calls > 0 {
+				perCall = float64(usec) / float64(calls)
+			}
+			fmt.Fprintf(sb, "cmdstat_%s:calls=%d,usec=%d,usec_per_call=%.2f,rejected_calls=%d,failed_calls=%d\r\n",
+				cmd.fullName(), calls, usec, perCall, rejected, atomic.LoadInt64(&cmd.stats.failedCalls))
+		}
+	}
+}
+
+func (s *Server) infoKeyspace(sb *strings.Builder) {
+	for i := 0; i < s.dbs.Len(); i++ {
+		keys, expires, avgTTL := s.dbs.DB(i).KeyspaceInfo()
+		if keys > 0 {
+			fmt.Fprintf(sb, "db%d:keys=%d,expires=%d,avg_ttl=%d\r\n", i, keys, expires, avgTTL.Milliseconds())
+		}
+	}
+}
+
+func humanBytes(n uint64) string {
+	units := []string{"B", "K", "M", "G", "T"}
+	value := float64(n)
+	i := 0
+	for value >= 1024 && i < len(units)-1 {
+		value /= 1024
+		i++
+	}
+	if i == 0 {
+		return fmt.Sprintf("%dB", n)
+	}
+	return fmt.Sprintf("%.2f%s", value, units[i])
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
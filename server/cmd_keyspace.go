@@ -0,0 +1,193 @@
+package server
+
+import (
+	"mini-redis/store"
+	"strconv"
+	"strings"
+)
+
+func delCommand(c *client, args []string) {
+	c.w.WriteInteger(int64(c.db.Del(args...)))
+}
+
+func moveCommand(c *client, args []string) {
+	index, errReply := c.srv.parseDBIndex(args[1], "ERR value is not an integer or out of range")
+	if errReply != "" {
+		c.w.WriteError(errReply)
+		return
+	}
+	if index == c.dbIndex {
+		c.w.WriteError("ERR source and destination objects are the same")
+		return
+	}
+	if c.srv.dbs.Move(args[0], c.dbIndex, index) {
+		c.w.WriteInteger(1)
+	} else {
+		c.w.WriteInteger(0)
+	}
+}
+
+func existsCommand(c *client, args []string) {
+	c.w.WriteInteger(int64(c.db.Exists(args...)))
+}
+
+// touchCommand counts the keys that exist. Keys have no access time to
+// update, so TOUCH is EXISTS under another name.
+func touchCommand(c *client, args []string) {
+	c.w.WriteInteger(int64(c.db.Exists(args...)))
+}
+
+func typeCommand(c *client, args []string) {
+	c.w.WriteSimpleString(c.db.Type(args[0]).String())
+}
+
+func renameCommand(c *client, args []string) {
+	if _, err := c.db.Rename(args[0], args[1], false); err != nil {
+		c.w.WriteError(err.Error())
+		return
+	}
+	c.w.WriteSimpleString("OK")
+}
+
+func renamenxCommand(c *client, args []string) {
+	renamed, err := c.db.Rename(args[0], args[1], true)
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return
+	}
+	if renamed {
+		c.w.WriteInteger(1)
+	} else {
+		c.w.WriteInteger(0)
+	}
+}
+
+func copyCommand(c *client, args []string) {
+	index, replace := c.dbIndex, false
+	for i := 2; i < len(args); i++ {
+		switch {
+		case strings.EqualFold(args[i], "REPLACE"):
+			replace = true
+		case strings.EqualFold(args[i], "DB") && i+1 < len(args):
+			var errReply string
+			index, errReply = c.srv.parseDBIndex(args[i+1], "ERR value is not an integer or out of range")
+			if errReply != "" {
+				c.w.WriteError(errReply)
+				return
+			}
+			i++
+		default:
+			c.w.WriteError("ERR syntax error")
+			return
+		}
+	}
+	if index == c.dbIndex && args[0] == args[1] {
+		c.w.WriteError("ERR source and destination objects are the same")
+		return
+	}
+	if c.srv.dbs.Copy(args[0], c.dbIndex, args[1], index, replace) {
+		c.w.WriteInteger(1)
+	} else {
+		c.w.WriteInteger(0)
+	}
+}
+
+func randomkeyCommand(c *client, args []string) {
+	key, ok := c.db.RandomKey()
+	if !ok {
+		c.w.WriteNull()
+		return
+	}
+	c.w.WriteBulkString(key)
+}
+
+func dbsizeCommand(c *client, args []string) {
+	c.w.WriteInteger(int64(c.db.DBSize()))
+}
+
+// scanArgs are the cursor and options of SCAN, SSCAN and HSCAN.
+type scanArgs struct {
+	cursor  uint64
+	count   int
+	pattern string
+	typ     string
+}
+
+// parseScanArgs parses "cursor [MATCH pattern] [COUNT count]", plus
+// [TYPE type] when allowType is set.
+func parseScanArgs(args []string, allowType bool) (scanArgs, string) {
+	parsed := scanArgs{count: 10}
+	cursor, err := strconv.ParseUint(args[0], 10, 64)
+	if err != nil {
+		return parsed, "ERR invalid cursor"
+	}
+	parsed.cursor = cursor
+	for i := 1; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			return parsed, "ERR syntax error"
+		}
+		value := args[i+1]
+		switch {
+		case strings.EqualFold(args[i], "MATCH"):
+			// "*" matches everything, so skip matching altogether.
+			if value == "*" {
+				value = ""
+			}
+			parsed.pattern = value
+		case strings.EqualFold(args[i], "COUNT"):
+			count, err := strconv.Atoi(value)
+			if err != nil {
+				return parsed, "ERR value is not an integer or out of range"
+			}
+			if count < 1 {
+				return parsed, "ERR syntax error"
+			}
+			parsed.count = count
+		case allowType && strings.EqualFold(args[i], "TYPE"):
+			parsed.typ = strings.ToLower(value)
+		default:
+			return parsed, "ERR syntax error"
+		}
+	}
+	return parsed, ""
+}
+
+func writeScanReply(c *client, next uint64, elements []string) {
+	c.w.WriteArrayHeader(2)
+	c.w.WriteBulkString(strconv.FormatUint(next, 10))
+	c.w.WriteArrayHeader(len(elements))
+	for _, element := range elements {
+		c.w.WriteBulkString(element)
+	}
+}
+
+func scanCommand(c *client, args []string) {
+	parsed, errReply := parseScanArgs(args, true)
+	if errReply != "" {
+		c.w.WriteError(errReply)
+		return
+	}
+	typ := store.Type(0)
+	if parsed.typ != "" {
+		var known bool
+		if typ, known = store.ParseType(parsed.typ); !known {
+			// No key can have a type this server does not implement.
+			writeScanReply(c, 0, nil)
+			return
+		}
+	}
+	keys, next := c.db.Scan(parsed.cursor, parsed.count, parsed.pattern, typ)
+	writeScanReply(c, next, keys)
+}
+
+func keysCommand(c *client, args []string) {
+	pattern := args[0]
+	if pattern == "*" {
+		pattern = ""
+	}
+	keys := c.db.Keys(pattern)
+	c.w.WriteArrayHeader(len(keys))
+	for _, key := range keys {
+		c.w.WriteBulkString(key)
+	}
+}
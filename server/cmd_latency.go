@@ -0,0 +1,107 @@
+package server
+
+import (
+	"fmt"
+	"mini-redis/protocol"
+	"strings"
+	"time"
+)
+
+// latencyAdvice explains, for LATENCY DOCTOR, what usually causes spikes
+// of each event and what to do about them.
+var latencyAdvice = map[string]string{
+	"command": "Some commands ran longer than latency-monitor-threshold. Use SLOWLOG GET to find out which, " +
+		"and avoid O(N) commands such as SMEMBERS on big keys.",
+	"fast-command": "Commands that run in constant time were slow. The process was probably starved of CPU or paused, " +
+		"for example by swapping or by the garbage collector under memory pressure.",
+	"snapshot-lock": "Copying the dataset for a snapshot held its lock, blocking every write meanwhile. " +
+		"The copy takes longer as the dataset grows; a longer snapshot-interval makes it happen less often.",
+	"snapshot-save": "Writing snapshots was slow. Check the disk dir points to and whether other processes compete for it.",
+	"expire-cycle": "Deleting expired keys stalled the server. This happens when many keys expire at the same time; " +
+		"adding some randomness to the TTLs spreads the work out.",
+	"lock-contention": "Clients waited for the dataset lock while another client or a background job held it. " +
+		"The snapshot-lock and expire-cycle events and SLOWLOG GET show what held it.",
+}
+
+func latencyLatestCommand(c *client, args []string) {
+	events := c.srv.latency.Latest()
+	c.w.WriteArrayHeader(len(events))
+	for _, event := range events {
+		c.w.WriteValue(protocol.NewArray(
+			protocol.NewBulkString(event.Name),
+			protocol.NewInteger(event.Latest.Time.Unix()),
+			protocol.NewInteger(event.Latest.Duration.Milliseconds()),
+			protocol.NewInteger(event.Max.Milliseconds()),
+		))
+	}
+}
+
+func latencyHistoryCommand(c *client, args []string) {
+	samples := c.srv.latency.History(args[0])
+	c.w.WriteArrayHeader(len(samples))
+	for _, sample := range samples {
+		c.w.WriteValue(protocol.NewArray(
+			protocol.NewInteger(sample.Time.Unix()),
+			protocol.NewInteger(sample.Duration.Milliseconds()),
+		))
+	}
+}
+
+func latencyResetCommand(c *client, args []string) {
+	c.w.WriteInteger(int64(c.srv.latency.Reset(args...)))
+}
+
+func latencyDoctorCommand(c *client, args []string) {
+	c.w.WriteValue(protocol.NewVerbatim("txt", c.srv.latencyReport()))
+}
+
+func (s *Server) latencyReport() string {
+	if s.config.Current().LatencyMonitorThreshold == 0 {
+		return "Latency monitoring is disabled. Use CONFIG SET latency-monitor-threshold <milliseconds> to enable it.\n"
+	}
+	events := s.latency.Latest()
+	if len(events) == 0 {
+		return "No latency spike was observed since the server started or the last LATENCY RESET.\n"
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Latency spikes were observed for %d event(s):\n\n", len(events))
+	for i, event := range events {
+		summary := s.latency.Summarize(event.Name)
+		fmt.Fprintf(&sb, "%d. %s: %d latency spike(s) (average %v, mean deviation %v", i+1, event.Name,
+			summary.Samples, roundLatency(summary.Avg), roundLatency(summary.MeanDeviation))
+		if summary.Samples > 1 {
+			fmt.Fprintf(&sb, ", period %.1f sec", summary.Period.Seconds())
+		}
+		fmt.Fprintf(&sb, "). Worst all time event %v.\n", roundLatency(summary.AllTimeMax))
+	}
+
+	sb.WriteString("\nAdvice:\n\n")
+	for _, event := range events {
+		if advice, ok := latencyAdvice[event.Name]; ok {
+			fmt.Fprintf(&sb, "- %s: %s\n", event.Name, advice)
+		}
+	}
+	return sb.String()
+}
+
+func roundLatency(d time.Duration) time.Duration {
+	return d.Round(time.Microsecond)
+}
+
+func latencyHelpCommand(c *client, args []string) {
+	c.w.WriteValue(protocol.NewStringArray([]string{
+		"LATENCY <subcommand> [<arg> [value] [opt] ...]. Subcommands are:",
+		"DOCTOR",
+		"    Return a human readable latency analysis report.",
+		"HISTORY <event>",
+		"    Return time-latency samples for the <event> class.",
+		"LATEST",
+		"    Return the latest latency samples for all events.",
+		"RESET [<event> ...]",
+		"    Reset latency data of one or more <event> classes.",
+		"    (default: reset all data for all event classes)",
+		"HELP",
+		"    Print this help.",
+	}))
+}
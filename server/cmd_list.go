@@ -0,0 +1,41 @@
+package server
+
+func lpushCommand(c *client, args []string) {
+	length, err := c.db.LPush(args[0], args[1:]...)
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return
+	}
+	c.w.WriteInteger(int64(length))
+}
+
+func rpushCommand(c *client, args []string) {
+	length, err := c.db.RPush(args[0], args[1:]...)
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return
+	}
+	c.w.WriteInteger(int64(length))
+}
+
+func lpopCommand(c *client, args []string) {
+	value, exists, err := c.db.LPop(args[0])
+	writePopReply(c, value, exists, err)
+}
+
+func rpopCommand(c *client, args []string) {
+	value, exists, err := c.db.RPop(args[0])
+	writePopReply(c, value, exists, err)
+}
+
+func writePopReply(c *client, value string, exists bool, err error) {
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return
+	}
+	if !exists {
+		c.w.WriteNull()
+		return
+	}
+	c.w.WriteBulkString(value)
+}
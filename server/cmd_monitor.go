@@ -0,0 +1,188 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// A monitor that falls this far behind is disconnected rather than
+// allowed to buffer without limit or slow down the clients it watches.
+const (
+	monitorMaxLines = 1024
+	monitorMaxBytes = 32 << 20
+)
+
+type monitorFeed struct {
+	c       *client
+	lines   chan string
+	stop    chan struct{}
+	once    sync.Once
+	pending int64
+}
+
+func monitorCommand(c *client, args []string) {
+	feed := &monitorFeed{
+		c:     c,
+		lines: make(chan string, monitorMaxLines),
+		stop:  make(chan struct{}),
+	}
+	c.mu.Lock()
+	c.monitor = feed
+	c.mu.Unlock()
+	c.w.WriteSimpleString("OK")
+
+	// The command loop holds wmu until the OK above is flushed, so the
+	// feed cannot overtake it.
+	go feed.run()
+	c.srv.addMonitor(feed)
+}
+
+// stopMonitor leaves MONITOR mode, if the client is in it.
+func (c *client) stopMonitor() {
+	if c.monitor == nil {
+		return
+	}
+	c.srv.removeMonitor(c.monitor)
+	c.monitor.close()
+	c.mu.Lock()
+	c.monitor = nil
+	c.mu.Unlock()
+}
+
+func (f *monitorFeed) close() {
+	f.once.Do(func() { close(f.stop) })
+}
+
+// run writes queued lines to the monitor. It checks stop again after
+// taking wmu because the command loop may have left MONITOR mode, and be
+// using the writer for ordinary replies, by the time it gets the lock.
+func (f *monitorFeed) run() {
+	for {
+		select {
+		case <-f.stop:
+			return
+		case line := <-f.lines:
+			atomic.AddInt64(&f.pending, -int64(len(line)))
+			f.c.wmu.Lock()
+			select {
+			case <-f.stop:
+				f.c.wmu.Unlock()
+				return
+			default:
+			}
+			f.c.w.WriteSimpleString(line)
+			if len(f.lines) == 0 {
+				f.c.w.Flush()
+			}
+			failed := f.c.w.Err() != nil
+			f.c.wmu.Unlock()
+			if failed {
+				f.c.conn.Close()
+				return
+			}
+		}
+	}
+}
+
+// send queues line without ever blocking, disconnecting the monitor if its
+// backlog is over the limits.
+func (f *monitorFeed) send(line string) {
+	size := atomic.AddInt64(&f.pending, int64(len(line)))
+	if size <= monitorMaxBytes {
+		select {
+		case f.lines <- line:
+			return
+		default:
+		}
+	}
+	f.c.srv.removeMonitor(f)
+	f.close()
+	f.c.conn.Close()
+	f.c.srv.log.Warning("Disconnecting MONITOR client that is not reading fast enough", "id", f.c.id, "addr", f.c.addr())
+}
+
+func (s *Server) addMonitor(feed *monitorFeed) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	feeds := []*monitorFeed{feed}
+	if current := s.monitors.Load(); current != nil {
+		feeds = append(feeds, *current...)
+	}
+	s.monitors.Store(&feeds)
+}
+
+func (s *Server) removeMonitor(feed *monitorFeed) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current := s.monitors.Load()
+	if current == nil {
+		return
+	}
+	feeds := []*monitorFeed{}
+	for _, other := range *current {
+		if other != feed {
+			feeds = append(feeds, other)
+		}
+	}
+	s.monitors.Store(&feeds)
+}
+
+// feedMonitors sends a command that ran at start to every monitor, in the
+// format of Redis: +<time> [<db> <addr>] "arg" "arg" ... Admin commands are
+// left out and passwords are redacted.
+func (s *Server) feedMonitors(c *client, cmd *command, argv []string, start time.Time) {
+	feeds := s.monitors.Load()
+	if feeds == nil || len(*feeds) == 0 || cmd.has(flagAdmin) {
+		return
+	}
+
+	addr := c.addr()
+	if c.conn.LocalAddr().Network() == "unix" {
+		addr = "unix:" + c.conn.LocalAddr().String()
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d.%06d [%d %s]", start.Unix(), start.Nanosecond()/1000, c.dbIndex, addr)
+	for _, arg := range redactedArgs(cmd, argv) {
+		sb.WriteByte(' ')
+		sb.WriteString(quoteArg(arg))
+	}
+	line := sb.String()
+	for _, feed := range *feeds {
+		feed.send(line)
+	}
+}
+
+// quoteArg quotes s the way redis-cli and MONITOR show arguments, so the
+// result is printable and fits on one line.
+func quoteArg(s string) string {
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		switch ch := s[i]; ch {
+		case '\\', '"':
+			sb.WriteByte('\\')
+			sb.WriteByte(ch)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\r':
+			sb.WriteString(`\r`)
+		case '\t':
+			sb.WriteString(`\t`)
+		case '\a':
+			sb.WriteString(`\a`)
+		case '\b':
+			sb.WriteString(`\b`)
+		default:
+			if ch < ' ' || ch > '~' {
+				fmt.Fprintf(&sb, `\x%02x`, ch)
+			} else {
+				sb.WriteByte(ch)
+			}
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
@@ -0,0 +1,107 @@
+package server
+
+import (
+	"context"
+	"strconv"
+	"strings"
+)
+
+func shutdownCommand(c *client, args []string) {
+	opts := shutdownOptions{}
+	abort := false
+	for _, arg := range args {
+		switch strings.ToUpper(arg) {
+		case "SAVE":
+			opts.save = true
+		case "NOSAVE":
+			opts.nosave = true
+		case "NOW":
+			opts.now = true
+		case "FORCE":
+			opts.force = true
+		case "ABORT":
+			abort = true
+		default:
+			c.w.WriteError("ERR syntax error")
+			return
+		}
+	}
+	if (opts.save && opts.nosave) || (abort && len(args) > 1) {
+		c.w.WriteError("ERR syntax error")
+		return
+	}
+
+	if abort {
+		if err := c.srv.abortShutdown(); err != nil {
+			c.w.WriteError(err.Error())
+			return
+		}
+		c.w.WriteSimpleString("OK")
+		return
+	}
+
+	// On success the connection is closed without a reply, like Redis.
+	if err := c.srv.stop(context.Background(), opts); err != nil {
+		c.w.WriteError(err.Error())
+	}
+}
+
+// parseDBIndex parses a database number, returning notInteger or the out
+// of range error as the reply when it is not valid.
+func (s *Server) parseDBIndex(arg, notInteger string) (int, string) {
+	index, err := strconv.Atoi(arg)
+	if err != nil {
+		return 0, notInteger
+	}
+	if index < 0 || index >= s.dbs.Len() {
+		return 0, "ERR DB index is out of range"
+	}
+	return index, ""
+}
+
+func swapdbCommand(c *client, args []string) {
+	first, errReply := c.srv.parseDBIndex(args[0], "ERR invalid first DB index")
+	if errReply != "" {
+		c.w.WriteError(errReply)
+		return
+	}
+	second, errReply := c.srv.parseDBIndex(args[1], "ERR invalid second DB index")
+	if errReply != "" {
+		c.w.WriteError(errReply)
+		return
+	}
+	c.srv.dbs.Swap(first, second)
+	c.w.WriteSimpleString("OK")
+}
+
+// validFlushMode accepts the optional ASYNC or SYNC argument of FLUSHDB
+// and FLUSHALL. Both flush the same way: the old data is dropped at once
+// and its memory is reclaimed by the garbage collector.
+func validFlushMode(args []string) bool {
+	if len(args) == 0 {
+		return true
+	}
+	if len(args) > 1 {
+		return false
+	}
+	mode := strings.ToUpper(args[0])
+	return mode == "ASYNC" || mode == "SYNC"
+}
+
+func flushdbCommand(c *client, args []string) {
+	if !validFlushMode(args) {
+		c.w.WriteError("ERR syntax error")
+		return
+	}
+	c.db.Flush()
+	c.w.WriteSimpleString("OK")
+}
+
+func flushallCommand(c *client, args []string) {
+	if !validFlushMode(args) {
+		c.w.WriteError("ERR syntax error")
+		return
+	}
+	c.srv.dbs.FlushAll()
+	c.w.WriteSimpleString("OK")
+}
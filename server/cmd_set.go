@@ -0,0 +1,45 @@
+package server
+
+func saddCommand(c *client, args []string) {
+	count, err := c.db.SAdd(args[0], args[1:]...)
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return
+	}
+	c.w.WriteInteger(int64(count))
+}
+
+func sremCommand(c *client, args []string) {
+	count, err := c.db.SRem(args[0], args[1:]...)
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return
+	}
+	c.w.WriteInteger(int64(count))
+}
+
+func smembersCommand(c *client, args []string) {
+	members, err := c.db.SMember(args[0])
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return
+	}
+	c.w.WriteSetHeader(len(members))
+	for _, member := range members {
+		c.w.WriteBulkString(member)
+	}
+}
+
+func sscanCommand(c *client, args []string) {
+	parsed, errReply := parseScanArgs(args[1:], false)
+	if errReply != "" {
+		c.w.WriteError(errReply)
+		return
+	}
+	members, next, err := c.db.SScan(args[0], parsed.cursor, parsed.count, parsed.pattern)
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return
+	}
+	writeScanReply(c, next, members)
+}
@@ -0,0 +1,61 @@
+package server
+
+import (
+	"mini-redis/protocol"
+	"strconv"
+)
+
+func slowlogGetCommand(c *client, args []string) {
+	count := 10
+	if len(args) > 1 {
+		c.w.WriteError("ERR wrong number of arguments for 'SLOWLOG|GET' command")
+		return
+	}
+	if len(args) == 1 {
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < -1 {
+			c.w.WriteError("ERR count should be greater than or equal to -1")
+			return
+		}
+		count = n
+	}
+
+	entries := c.srv.slow.Entries(count)
+	c.w.WriteArrayHeader(len(entries))
+	for _, entry := range entries {
+		c.w.WriteValue(protocol.NewArray(
+			protocol.NewInteger(entry.ID),
+			protocol.NewInteger(entry.Time.Unix()),
+			protocol.NewInteger(entry.Duration.Microseconds()),
+			protocol.NewStringArray(entry.Args),
+			protocol.NewBulkString(entry.ClientAddr),
+			protocol.NewBulkString(entry.ClientName),
+		))
+	}
+}
+
+func slowlogLenCommand(c *client, args []string) {
+	c.w.WriteInteger(int64(c.srv.slow.Len()))
+}
+
+func slowlogResetCommand(c *client, args []string) {
+	c.srv.slow.Reset()
+	c.w.WriteSimpleString("OK")
+}
+
+func slowlogHelpCommand(c *client, args []string) {
+	c.w.WriteValue(protocol.NewStringArray([]string{
+		"SLOWLOG <subcommand> [<arg> [value] [opt] ...]. Subcommands are:",
+		"GET [<count>]",
+		"    Return top <count> entries from the slowlog (default: 10, -1 mean all).",
+		"    Entries are made of:",
+		"    id, timestamp, time in microseconds, arguments array, client IP and port,",
+		"    client name",
+		"LEN",
+		"    Return the length of the slowlog.",
+		"RESET",
+		"    Reset the slowlog.",
+		"HELP",
+		"    Print this help.",
+	}))
+}
@@ -0,0 +1,34 @@
+package server
+
+import "strconv"
+
+func setCommand(c *client, args []string) {
+	if len(args) > 3 {
+		c.w.WriteError("ERR syntax error")
+		return
+	}
+	ttl := 0
+	if len(args) == 3 {
+		parsedTTL, err := strconv.Atoi(args[2])
+		if err != nil {
+			c.w.WriteError("ERR invalid TTL value")
+			return
+		}
+		ttl = parsedTTL
+	}
+	c.db.Set(args[0], args[1], ttl)
+	c.w.WriteSimpleString("OK")
+}
+
+func getCommand(c *client, args []string) {
+	value, exists, err := c.db.Get(args[0])
+	if err != nil {
+		c.w.WriteError(err.Error())
+		return
+	}
+	if !exists {
+		c.w.WriteNull()
+		return
+	}
+	c.w.WriteBulkString(value)
+}
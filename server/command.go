@@ -0,0 +1,470 @@
+package server
+
+import (
+	"fmt"
+	"mini-redis/metrics"
+	"mini-redis/protocol"
+	"mini-redis/slowlog"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	flagWrite = 1 << iota
+	flagReadonly
+	flagAdmin
+	flagPubSub
+	flagBlocking
+	flagFast
+	flagLoading
+	flagStale
+	flagNoAuth
+)
+
+var flagNames = []struct {
+	flag int
+	name string
+}{
+	{flagWrite, "write"},
+	{flagReadonly, "readonly"},
+	{flagAdmin, "admin"},
+	{flagPubSub, "pubsub"},
+	{flagBlocking, "blocking"},
+	{flagFast, "fast"},
+	{flagLoading, "loading"},
+	{flagStale, "stale"},
+	{flagNoAuth, "no_auth"},
+}
+
+// Key access flags say what ACL key permissions a key position needs.
+const (
+	keyRead = 1 << iota
+	keyWrite
+)
+
+// command describes one entry of the command table. Arity counts the
+// command name itself; a negative arity means "at least -arity arguments".
+// firstKey, lastKey and step locate the keys in the full argument vector,
+// with a negative lastKey counting from the end. keyFlags gives the access
+// each key needs, in the order keys returns them, with the last entry
+// repeating; without it, keys of write commands need write access and the
+// others read access.
+type command struct {
+	name       string
+	arity      int
+	flags      int
+	firstKey   int
+	lastKey    int
+	step       int
+	keyFlags   []int
+	group      string
+	since      string
+	summary    string
+	complexity string
+	// aclCategories are ACL categories beyond those the flags and group
+	// imply.
+	aclCategories []string
+	handler       func(c *client, args []string)
+
+	parent      *command
+	subcommands map[string]*command
+
+	stats commandStats
+}
+
+// commandStats are the per-command counters INFO commandstats reports.
+// They are updated atomically.
+type commandStats struct {
+	calls         int64
+	usec          int64
+	rejectedCalls int64
+	failedCalls   int64
+	latency       metrics.Histogram
+}
+
+func (s *commandStats) reset() {
+	atomic.StoreInt64(&s.calls, 0)
+	atomic.StoreInt64(&s.usec, 0)
+	atomic.StoreInt64(&s.rejectedCalls, 0)
+	atomic.StoreInt64(&s.failedCalls, 0)
+	s.latency.Reset()
+}
+
+var commandTable = map[string]*command{}
+
+func registerCommand(cmd *command, subcommands ...*command) {
+	if len(subcommands) > 0 {
+		cmd.subcommands = map[string]*command{}
+		for _, sub := range subcommands {
+			sub.parent = cmd
+			if sub.group == "" {
+				sub.group = cmd.group
+			}
+			cmd.subcommands[sub.name] = sub
+		}
+	}
+	commandTable[cmd.name] = cmd
+}
+
+func (cmd *command) fullName() string {
+	if cmd.parent != nil {
+		return cmd.parent.name + "|" + cmd.name
+	}
+	return cmd.name
+}
+
+func (cmd *command) has(flag int) bool {
+	return cmd.flags&flag != 0
+}
+
+// keyAccess returns the key access flags of the i-th key of cmd.
+func (cmd *command) keyAccess(i int) int {
+	if len(cmd.keyFlags) > 0 {
+		return cmd.keyFlags[min(i, len(cmd.keyFlags)-1)]
+	}
+	if cmd.has(flagWrite) {
+		return keyWrite
+	}
+	return keyRead
+}
+
+func (cmd *command) flagList() []string {
+	names := []string{}
+	for _, f := range flagNames {
+		if cmd.has(f.flag) {
+			names = append(names, f.name)
+		}
+	}
+	return names
+}
+
+// categories derives the ACL categories of a command from its flags and
+// group.
+func (cmd *command) categories() []string {
+	categories := []string{}
+	for _, f := range []struct {
+		flag     int
+		category string
+	}{
+		{flagWrite, "write"},
+		{flagReadonly, "read"},
+		{flagAdmin, "admin"},
+		{flagAdmin, "dangerous"},
+		{flagPubSub, "pubsub"},
+		{flagBlocking, "blocking"},
+	} {
+		if cmd.has(f.flag) {
+			categories = append(categories, f.category)
+		}
+	}
+	if cmd.has(flagFast) {
+		categories = append(categories, "fast")
+	} else {
+		categories = append(categories, "slow")
+	}
+	switch cmd.group {
+	case "string", "list", "hash", "set", "connection":
+		categories = append(categories, cmd.group)
+	case "generic":
+		categories = append(categories, "keyspace")
+	}
+	return append(categories, cmd.aclCategories...)
+}
+
+// lookupCommand resolves argv to a command, descending into subcommands,
+// and validates its arity. On failure it returns the error reply to send.
+func lookupCommand(argv []string) (*command, string) {
+	cmd, ok := commandTable[strings.ToLower(argv[0])]
+	if !ok {
+		return nil, fmt.Sprintf("ERR unknown command '%s'", strings.ToUpper(argv[0]))
+	}
+
+	if cmd.subcommands != nil && len(argv) >= 2 {
+		sub, ok := cmd.subcommands[strings.ToLower(argv[1])]
+		if !ok {
+			return nil, fmt.Sprintf("ERR unknown subcommand '%s'. Try %s HELP.", argv[1], strings.ToUpper(cmd.name))
+		}
+		cmd = sub
+	}
+
+	if (cmd.arity > 0 && len(argv) != cmd.arity) || len(argv) < -cmd.arity {
+		return nil, fmt.Sprintf("ERR wrong number of arguments for '%s' command", strings.ToUpper(cmd.fullName()))
+	}
+	return cmd, ""
+}
+
+// argOffset is the number of leading argv entries naming the command.
+func (cmd *command) argOffset() int {
+	if cmd.parent != nil {
+		return 2
+	}
+	return 1
+}
+
+func (cmd *command) keys(argv []string) []string {
+	if cmd.firstKey <= 0 {
+		return nil
+	}
+	last := cmd.lastKey
+	if last < 0 {
+		last = len(argv) + last
+	}
+	keys := []string{}
+	for i := cmd.firstKey; i <= last && i < len(argv); i += cmd.step {
+		keys = append(keys, argv[i])
+	}
+	return keys
+}
+
+func executeCommand(c *client, argv []string) {
+	silent := c.replyMode != replyOn
+	if c.replyMode == replySkip {
+		c.replyMode = replyOn
+	}
+	c.w.SetDiscard(silent)
+	defer c.w.SetDiscard(false)
+
+	cmd, errReply := lookupCommand(argv)
+	if cmd == nil {
+		// Like Redis, record no name rather than one the client made up,
+		// which could break the CLIENT LIST format.
+		c.touch("NULL")
+		c.w.WriteError(errReply)
+		return
+	}
+	c.touch(cmd.fullName())
+	if cmd.handler == nil {
+		c.w.WriteError(fmt.Sprintf("ERR missing subcommand. Try %s HELP.", strings.ToUpper(cmd.name)))
+		return
+	}
+	if c.monitor != nil && cmd.name != "quit" && cmd.name != "reset" {
+		atomic.AddInt64(&cmd.stats.rejectedCalls, 1)
+		c.w.WriteError("ERR Only QUIT and RESET are allowed in MONITOR mode")
+		return
+	}
+	if !c.isAuthenticated() && !cmd.has(flagNoAuth) {
+		atomic.AddInt64(&cmd.stats.rejectedCalls, 1)
+		c.w.WriteError("NOAUTH Authentication required.")
+		return
+	}
+	if errReply := checkPermissions(c, cmd, argv); errReply != "" {
+		atomic.AddInt64(&cmd.stats.rejectedCalls, 1)
+		c.w.WriteError(errReply)
+		return
+	}
+	// SHUTDOWN is not counted as in flight: SHUTDOWN ABORT has to run while
+	// a shutdown drains, and the drain must not wait for SHUTDOWN itself.
+	if cmd.name != "shutdown" {
+		if !c.srv.beginCommand(c, cmd) {
+			return
+		}
+		defer c.srv.endCommand()
+	}
+	errors := c.w.Errors()
+	start := time.Now()
+	cmd.handler(c, argv[cmd.argOffset():])
+	duration := time.Since(start)
+
+	atomic.AddInt64(&cmd.stats.calls, 1)
+	atomic.AddInt64(&cmd.stats.usec, duration.Microseconds())
+	cmd.stats.latency.Observe(duration)
+	if c.w.Errors() > errors {
+		atomic.AddInt64(&cmd.stats.failedCalls, 1)
+	} else if cmd.has(flagWrite) {
+		atomic.AddInt64(&c.srv.dirty, 1)
+	}
+	c.srv.recordSlowCommand(c, cmd, argv, duration)
+	if cmd.has(flagFast) {
+		c.srv.recordLatency("fast-command", duration)
+	} else {
+		c.srv.recordLatency("command", duration)
+	}
+	c.srv.feedMonitors(c, cmd, argv, start)
+}
+
+// redactedArgs returns argv with passwords replaced, for the logs that
+// record commands.
+func redactedArgs(cmd *command, argv []string) []string {
+	redacted := append([]string(nil), argv...)
+	switch cmd.fullName() {
+	case "auth":
+		for i := 1; i < len(redacted); i++ {
+			redacted[i] = "(redacted)"
+		}
+	case "hello":
+		for i := 2; i < len(redacted); i++ {
+			if strings.EqualFold(redacted[i], "AUTH") && i+2 < len(redacted) {
+				redacted[i+1], redacted[i+2] = "(redacted)", "(redacted)"
+				i += 2
+			}
+		}
+	case "acl|setuser":
+		for i := 3; i < len(redacted); i++ {
+			if strings.HasPrefix(redacted[i], ">") || strings.HasPrefix(redacted[i], "<") {
+				redacted[i] = "(redacted)"
+			}
+		}
+	}
+	return redacted
+}
+
+func (s *Server) recordSlowCommand(c *client, cmd *command, argv []string, duration time.Duration) {
+	cfg := s.config.Current()
+	if cfg.SlowlogLogSlowerThan < 0 || duration < time.Duration(cfg.SlowlogLogSlowerThan)*time.Microsecond {
+		return
+	}
+	s.slow.Add(slowlog.Entry{
+		Time:       time.Now(),
+		Duration:   duration,
+		Args:       redactedArgs(cmd, argv),
+		ClientAddr: c.addr(),
+		ClientName: c.getName(),
+	}, cfg.SlowlogMaxLen)
+}
+
+func sortedCommands(table map[string]*command) []*command {
+	commands := make([]*command, 0, len(table))
+	for _, cmd := range table {
+		commands = append(commands, cmd)
+	}
+	sort.Slice(commands, func(i, j int) bool { return commands[i].name < commands[j].name })
+	return commands
+}
+
+func commandInfo(cmd *command) protocol.Value {
+	flags := []protocol.Value{}
+	for _, name := range cmd.flagList() {
+		flags = append(flags, protocol.NewSimpleString(name))
+	}
+	subcommands := []protocol.Value{}
+	for _, sub := range sortedCommands(cmd.subcommands) {
+		subcommands = append(subcommands, commandInfo(sub))
+	}
+	return protocol.NewArray(
+		protocol.NewBulkString(cmd.fullName()),
+		protocol.NewInteger(int64(cmd.arity)),
+		protocol.NewSet(flags...),
+		protocol.NewInteger(int64(cmd.firstKey)),
+		protocol.NewInteger(int64(cmd.lastKey)),
+		protocol.NewInteger(int64(cmd.step)),
+		protocol.NewSet(),
+		protocol.NewArray(),
+		protocol.NewArray(),
+		protocol.NewArray(subcommands...),
+	)
+}
+
+func commandDocs(cmd *command) protocol.Value {
+	docs := []protocol.Value{
+		protocol.NewBulkString("summary"), protocol.NewBulkString(cmd.summary),
+		protocol.NewBulkString("since"), protocol.NewBulkString(cmd.since),
+		protocol.NewBulkString("group"), protocol.NewBulkString(cmd.group),
+	}
+	if cmd.complexity != "" {
+		docs = append(docs, protocol.NewBulkString("complexity"), protocol.NewBulkString(cmd.complexity))
+	}
+	if len(cmd.subcommands) > 0 {
+		subcommands := []protocol.Value{}
+		for _, sub := range sortedCommands(cmd.subcommands) {
+			subcommands = append(subcommands, protocol.NewBulkString(sub.fullName()), commandDocs(sub))
+		}
+		docs = append(docs, protocol.NewBulkString("subcommands"), protocol.NewMap(subcommands...))
+	}
+	return protocol.NewMap(docs...)
+}
+
+func lookupCommandByName(name string) *command {
+	parent, sub, found := strings.Cut(strings.ToLower(name), "|")
+	cmd := commandTable[parent]
+	if cmd == nil || !found {
+		return cmd
+	}
+	return cmd.subcommands[sub]
+}
+
+func commandCommand(c *client, args []string) {
+	replies := []protocol.Value{}
+	for _, cmd := range sortedCommands(commandTable) {
+		replies = append(replies, commandInfo(cmd))
+	}
+	c.w.WriteValue(protocol.NewArray(replies...))
+}
+
+func commandCountCommand(c *client, args []string) {
+	c.w.WriteInteger(int64(len(commandTable)))
+}
+
+func commandInfoCommand(c *client, args []string) {
+	if len(args) == 0 {
+		commandCommand(c, args)
+		return
+	}
+	replies := []protocol.Value{}
+	for _, name := range args {
+		if cmd := lookupCommandByName(name); cmd != nil {
+			replies = append(replies, commandInfo(cmd))
+		} else {
+			replies = append(replies, protocol.NewNullArray())
+		}
+	}
+	c.w.WriteValue(protocol.NewArray(replies...))
+}
+
+func commandDocsCommand(c *client, args []string) {
+	commands := []*command{}
+	if len(args) == 0 {
+		commands = sortedCommands(commandTable)
+	}
+	for _, name := range args {
+		if cmd := lookupCommandByName(name); cmd != nil {
+			commands = append(commands, cmd)
+		}
+	}
+	docs := []protocol.Value{}
+	for _, cmd := range commands {
+		docs = append(docs, protocol.NewBulkString(cmd.fullName()), commandDocs(cmd))
+	}
+	c.w.WriteValue(protocol.NewMap(docs...))
+}
+
+func commandGetKeysCommand(c *client, args []string) {
+	cmd, errReply := lookupCommand(args)
+	if cmd == nil {
+		if strings.HasPrefix(errReply, "ERR unknown command") {
+			c.w.WriteError("ERR Invalid command specified")
+		} else {
+			c.w.WriteError("ERR Invalid number of arguments specified for command")
+		}
+		return
+	}
+	keys := cmd.keys(args)
+	if len(keys) == 0 {
+		c.w.WriteError("ERR The command has no key arguments")
+		return
+	}
+	c.w.WriteValue(protocol.NewStringArray(keys))
+}
+
+func commandHelpCommand(c *client, args []string) {
+	c.w.WriteValue(protocol.NewStringArray([]string{
+		"COMMAND <subcommand> [<arg> [value] [opt] ...]. Subcommands are:",
+		"(no subcommand)",
+		"    Return details about all commands.",
+		"COUNT",
+		"    Return the total number of commands in this server.",
+		"INFO [<command-name> ...]",
+		"    Return details about multiple commands.",
+		"    If no command names are given, documentation details for all",
+		"    commands are returned.",
+		"DOCS [<command-name> ...]",
+		"    Return documentation details about multiple commands.",
+		"    If no command names are given, documentation details for all",
+		"    commands are returned.",
+		"GETKEYS <full-command>",
+		"    Return the keys from a full command.",
+		"HELP",
+		"    Print this help.",
+	}))
+}
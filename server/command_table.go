@@ -0,0 +1,228 @@
+package server
+
+func init() {
+	registerCommand(&command{name: "command", arity: -1, flags: flagLoading | flagStale, group: "server", since: "2.8.13",
+		summary: "Returns detailed information about all commands.", complexity: "O(N) where N is the total number of Redis commands",
+		handler: commandCommand},
+		&command{name: "count", arity: 2, flags: flagLoading | flagStale, since: "2.8.13",
+			summary: "Returns a count of commands.", complexity: "O(1)", handler: commandCountCommand},
+		&command{name: "info", arity: -2, flags: flagLoading | flagStale, since: "2.8.13",
+			summary: "Returns information about one, multiple or all commands.", complexity: "O(N) where N is the number of commands to look up",
+			handler: commandInfoCommand},
+		&command{name: "docs", arity: -2, flags: flagLoading | flagStale, since: "7.0.0",
+			summary: "Returns documentary information about one, multiple or all commands.", complexity: "O(N) where N is the number of commands to look up",
+			handler: commandDocsCommand},
+		&command{name: "getkeys", arity: -3, flags: flagLoading | flagStale, since: "2.8.13",
+			summary: "Extracts the key names from an arbitrary command.", complexity: "O(N) where N is the number of arguments to the command",
+			handler: commandGetKeysCommand},
+		&command{name: "help", arity: 2, flags: flagLoading | flagStale, since: "5.0.0",
+			summary: "Returns helpful text about the different subcommands.", complexity: "O(1)", handler: commandHelpCommand},
+	)
+
+	registerCommand(&command{name: "config", arity: -2, group: "server", since: "2.0.0",
+		summary: "A container for server configuration commands.", complexity: "Depends on subcommand."},
+		&command{name: "get", arity: -3, flags: flagAdmin | flagLoading | flagStale, since: "2.0.0",
+			summary: "Returns the effective values of configuration parameters.", complexity: "O(N) when N is the number of configuration parameters provided",
+			handler: configGetCommand},
+		&command{name: "set", arity: -4, flags: flagAdmin | flagLoading | flagStale, since: "2.0.0",
+			summary: "Sets configuration parameters in-flight.", complexity: "O(N) when N is the number of configuration parameters provided",
+			handler: configSetCommand},
+		&command{name: "rewrite", arity: 2, flags: flagAdmin | flagLoading | flagStale, since: "2.8.0",
+			summary: "Persists the effective configuration to file.", complexity: "O(1)", handler: configRewriteCommand},
+		&command{name: "resetstat", arity: 2, flags: flagAdmin | flagLoading | flagStale, since: "2.0.0",
+			summary: "Resets the server's statistics.", complexity: "O(1)", handler: configResetStatCommand},
+		&command{name: "help", arity: 2, flags: flagLoading | flagStale, since: "5.0.0",
+			summary: "Returns helpful text about the different subcommands.", complexity: "O(1)", handler: configHelpCommand},
+	)
+
+	registerCommand(&command{name: "info", arity: -1, flags: flagLoading | flagStale, group: "server", since: "1.0.0",
+		summary: "Returns information and statistics about the server.", complexity: "O(1)", handler: infoCommand})
+
+	registerCommand(&command{name: "monitor", arity: 1, flags: flagAdmin | flagLoading | flagStale, group: "server", since: "1.0.0",
+		summary: "Listens for all requests received by the server in real-time.", complexity: "", handler: monitorCommand})
+
+	registerCommand(&command{name: "slowlog", arity: -2, group: "server", since: "2.2.12",
+		summary: "A container for slow log commands.", complexity: "Depends on subcommand."},
+		&command{name: "get", arity: -2, flags: flagAdmin | flagLoading | flagStale, since: "2.2.12",
+			summary: "Returns the slow log's entries.", complexity: "O(N) where N is the number of entries returned", handler: slowlogGetCommand},
+		&command{name: "len", arity: 2, flags: flagAdmin | flagLoading | flagStale, since: "2.2.12",
+			summary: "Returns the number of entries in the slow log.", complexity: "O(1)", handler: slowlogLenCommand},
+		&command{name: "reset", arity: 2, flags: flagAdmin | flagLoading | flagStale, since: "2.2.12",
+			summary: "Clears all entries from the slow log.", complexity: "O(N) where N is the number of entries in the slowlog", handler: slowlogResetCommand},
+		&command{name: "help", arity: 2, flags: flagLoading | flagStale, since: "6.2.0",
+			summary: "Show helpful text about the different subcommands", complexity: "O(1)", handler: slowlogHelpCommand},
+	)
+
+	registerCommand(&command{name: "latency", arity: -2, group: "server", since: "2.8.13",
+		summary: "A container for latency diagnostics commands.", complexity: "Depends on subcommand."},
+		&command{name: "latest", arity: 2, flags: flagAdmin | flagLoading | flagStale, since: "2.8.13",
+			summary: "Returns the latest latency samples for all events.", complexity: "O(1)", handler: latencyLatestCommand},
+		&command{name: "history", arity: 3, flags: flagAdmin | flagLoading | flagStale, since: "2.8.13",
+			summary: "Returns timestamp-latency samples for an event.", complexity: "O(1)", handler: latencyHistoryCommand},
+		&command{name: "reset", arity: -2, flags: flagAdmin | flagLoading | flagStale, since: "2.8.13",
+			summary: "Resets the latency data for one or more events.", complexity: "O(1)", handler: latencyResetCommand},
+		&command{name: "doctor", arity: 2, flags: flagAdmin | flagLoading | flagStale, since: "2.8.13",
+			summary: "Returns a human-readable latency analysis report.", complexity: "O(1)", handler: latencyDoctorCommand},
+		&command{name: "help", arity: 2, flags: flagLoading | flagStale, since: "2.8.13",
+			summary: "Returns helpful text about the different subcommands.", complexity: "O(1)", handler: latencyHelpCommand},
+	)
+
+	registerCommand(&command{name: "shutdown", arity: -1, flags: flagAdmin | flagLoading | flagStale, group: "server", since: "1.0.0",
+		summary: "Synchronously saves the database(s) to disk and shuts down the Redis server.", complexity: "O(N) when saving, where N is the total number of keys in all databases",
+		handler: shutdownCommand})
+
+	registerCommand(&command{name: "client", arity: -2, group: "connection", since: "2.4.0",
+		summary: "A container for client connection commands.", complexity: "Depends on subcommand."},
+		&command{name: "id", arity: 2, flags: flagLoading | flagStale, since: "5.0.0",
+			summary: "Returns the unique client ID of the connection.", complexity: "O(1)", handler: clientIDCommand},
+		&command{name: "info", arity: 2, flags: flagLoading | flagStale, since: "6.2.0",
+			summary: "Returns information about the connection.", complexity: "O(1)", handler: clientInfoCommand},
+		&command{name: "list", arity: -2, flags: flagAdmin | flagLoading | flagStale, since: "2.4.0",
+			summary: "Lists open connections.", complexity: "O(N) where N is the number of client connections", handler: clientListCommand},
+		&command{name: "kill", arity: -3, flags: flagAdmin | flagLoading | flagStale, since: "2.4.0",
+			summary: "Terminates open connections.", complexity: "O(N) where N is the number of client connections", handler: clientKillCommand},
+		&command{name: "setname", arity: 3, flags: flagLoading | flagStale, since: "2.6.9",
+			summary: "Sets the connection name.", complexity: "O(1)", handler: clientSetNameCommand},
+		&command{name: "getname", arity: 2, flags: flagLoading | flagStale, since: "2.6.9",
+			summary: "Returns the name of the connection.", complexity: "O(1)", handler: clientGetNameCommand},
+		&command{name: "pause", arity: -3, flags: flagAdmin | flagLoading | flagStale, since: "3.0.0",
+			summary: "Suspends commands processing.", complexity: "O(1)", handler: clientPauseCommand},
+		&command{name: "unpause", arity: 2, flags: flagAdmin | flagLoading | flagStale, since: "6.2.0",
+			summary: "Resumes processing commands from paused clients.", complexity: "O(N) Where N is the number of paused clients", handler: clientUnpauseCommand},
+		&command{name: "reply", arity: 3, flags: flagLoading | flagStale, since: "3.2.0",
+			summary: "Instructs the server whether to reply to commands.", complexity: "O(1)", handler: clientReplyCommand},
+		&command{name: "help", arity: 2, flags: flagLoading | flagStale, since: "5.0.0",
+			summary: "Returns helpful text about the different subcommands.", complexity: "O(1)", handler: clientHelpCommand},
+	)
+
+	registerCommand(&command{name: "hello", arity: -1, flags: flagFast | flagLoading | flagStale | flagNoAuth, group: "connection", since: "6.0.0",
+		summary: "Handshakes with the Redis server.", complexity: "O(1)", handler: helloCommand})
+
+	registerCommand(&command{name: "quit", arity: -1, flags: flagFast | flagLoading | flagStale | flagNoAuth, group: "connection", since: "1.0.0",
+		summary: "Closes the connection.", complexity: "O(1)", handler: quitCommand})
+
+	registerCommand(&command{name: "reset", arity: 1, flags: flagFast | flagLoading | flagStale | flagNoAuth, group: "connection", since: "6.2.0",
+		summary: "Resets the connection.", complexity: "O(1)", handler: resetCommand})
+
+	registerCommand(&command{name: "select", arity: 2, flags: flagFast | flagLoading | flagStale, group: "connection", since: "1.0.0",
+		summary: "Changes the selected database.", complexity: "O(1)", handler: selectCommand})
+	registerCommand(&command{name: "auth", arity: -2, flags: flagFast | flagLoading | flagStale | flagNoAuth, group: "connection", since: "1.0.0",
+		summary: "Authenticates the connection.", complexity: "O(N) where N is the number of passwords defined for the user", handler: authCommand})
+
+	registerCommand(&command{name: "acl", arity: -2, group: "server", since: "6.0.0",
+		summary: "A container for Access List Control commands.", complexity: "Depends on subcommand."},
+		&command{name: "cat", arity: -2, flags: flagLoading | flagStale, since: "6.0.0",
+			summary: "Lists the ACL categories, or the commands inside a category.", complexity: "O(1) since the categories and commands are a fixed set.", handler: aclCatCommand},
+		&command{name: "deluser", arity: -3, flags: flagAdmin | flagLoading | flagStale, since: "6.0.0",
+			summary: "Deletes ACL users, and terminates their connections.", complexity: "O(1) amortized time considering the typical user.", handler: aclDelUserCommand},
+		&command{name: "getuser", arity: 3, flags: flagAdmin | flagLoading | flagStale, since: "6.0.0",
+			summary: "Lists the ACL rules of a user.", complexity: "O(N). Where N is the number of password, command and pattern rules that the user has.", handler: aclGetUserCommand},
+		&command{name: "list", arity: 2, flags: flagAdmin | flagLoading | flagStale, since: "6.0.0",
+			summary: "Dumps the effective rules in ACL file format.", complexity: "O(N). Where N is the number of configured users.", handler: aclListCommand},
+		&command{name: "load", arity: 2, flags: flagAdmin | flagLoading | flagStale, since: "6.0.0",
+			summary: "Reloads the rules from the configured ACL file.", complexity: "O(N). Where N is the number of configured users.", handler: aclLoadCommand},
+		&command{name: "log", arity: -2, flags: flagAdmin | flagLoading | flagStale, since: "6.0.0",
+			summary: "Lists recent security events generated due to ACL rules.", complexity: "O(N) with N being the number of entries shown.", handler: aclLogCommand},
+		&command{name: "save", arity: 2, flags: flagAdmin | flagLoading | flagStale, since: "6.0.0",
+			summary: "Saves the effective ACL rules in the configured ACL file.", complexity: "O(N). Where N is the number of configured users.", handler: aclSaveCommand},
+		&command{name: "setuser", arity: -3, flags: flagAdmin | flagLoading | flagStale, since: "6.0.0",
+			summary: "Creates and modifies an ACL user and its rules.", complexity: "O(N). Where N is the number of rules provided.", handler: aclSetUserCommand},
+		&command{name: "users", arity: 2, flags: flagAdmin | flagLoading | flagStale, since: "6.0.0",
+			summary: "Lists all ACL users.", complexity: "O(N). Where N is the number of configured users.", handler: aclUsersCommand},
+		&command{name: "whoami", arity: 2, flags: flagLoading | flagStale, since: "6.0.0",
+			summary: "Returns the authenticated username of the current connection.", complexity: "O(1)", handler: aclWhoAmICommand},
+		&command{name: "help", arity: 2, flags: flagLoading | flagStale, since: "6.0.0",
+			summary: "Returns helpful text about the different subcommands.", complexity: "O(1)", handler: aclHelpCommand},
+	)
+
+	registerCommand(&command{name: "set", arity: -3, flags: flagWrite, firstKey: 1, lastKey: 1, step: 1, group: "string", since: "1.0.0",
+		summary: "Sets the string value of a key, ignoring its type. The key is created if it doesn't exist.", complexity: "O(1)", handler: setCommand})
+	registerCommand(&command{name: "get", arity: 2, flags: flagReadonly | flagFast, firstKey: 1, lastKey: 1, step: 1, group: "string", since: "1.0.0",
+		summary: "Returns the string value of a key.", complexity: "O(1)", handler: getCommand})
+
+	registerCommand(&command{name: "del", arity: -2, flags: flagWrite, firstKey: 1, lastKey: -1, step: 1, group: "generic", since: "1.0.0",
+		summary: "Deletes one or more keys.", complexity: "O(N) where N is the number of keys that will be removed", handler: delCommand})
+
+	registerCommand(&command{name: "exists", arity: -2, flags: flagReadonly | flagFast, firstKey: 1, lastKey: -1, step: 1, group: "generic", since: "1.0.0",
+		summary: "Determines whether one or more keys exist.", complexity: "O(N) where N is the number of keys to check.", handler: existsCommand})
+	registerCommand(&command{name: "touch", arity: -2, flags: flagReadonly | flagFast, firstKey: 1, lastKey: -1, step: 1, group: "generic", since: "3.2.1",
+		summary: "Returns the number of existing keys out of those specified after updating the time they were last accessed.", complexity: "O(N) where N is the number of keys that will be touched.",
+		handler: touchCommand})
+	registerCommand(&command{name: "type", arity: 2, flags: flagReadonly | flagFast, firstKey: 1, lastKey: 1, step: 1, group: "generic", since: "1.0.0",
+		summary: "Determines the type of value stored at a key.", complexity: "O(1)", handler: typeCommand})
+	registerCommand(&command{name: "rename", arity: 3, flags: flagWrite, firstKey: 1, lastKey: 2, step: 1, keyFlags: []int{keyRead | keyWrite, keyWrite}, group: "generic", since: "1.0.0",
+		summary: "Renames a key and overwrites the destination.", complexity: "O(1)", handler: renameCommand})
+	registerCommand(&command{name: "renamenx", arity: 3, flags: flagWrite | flagFast, firstKey: 1, lastKey: 2, step: 1, keyFlags: []int{keyRead | keyWrite, keyWrite}, group: "generic", since: "1.0.0",
+		summary: "Renames a key only when the target key name doesn't exist.", complexity: "O(1)", handler: renamenxCommand})
+	registerCommand(&command{name: "copy", arity: -3, flags: flagWrite, firstKey: 1, lastKey: 2, step: 1, keyFlags: []int{keyRead, keyWrite}, group: "generic", since: "6.2.0",
+		summary: "Copies the value of a key to a new key.", complexity: "O(N) worst case for collections, where N is the number of nested items. O(1) for string values.",
+		handler: copyCommand})
+	registerCommand(&command{name: "randomkey", arity: 1, flags: flagReadonly, group: "generic", since: "1.0.0",
+		summary: "Returns a random key name from the database.", complexity: "O(1)", handler: randomkeyCommand})
+	registerCommand(&command{name: "scan", arity: -2, flags: flagReadonly, group: "generic", since: "2.8.0",
+		summary: "Iterates over the key names in the database.", complexity: "O(COUNT) for every call. O(N) for a complete iteration, where N is the number of keys in the database.",
+		handler: scanCommand})
+	registerCommand(&command{name: "keys", arity: 2, flags: flagReadonly, group: "generic", since: "1.0.0",
+		summary: "Returns all key names that match a pattern.", complexity: "O(N) with N being the number of keys in the database, under the assumption that the key names in the database and the given pattern have limited length.",
+		aclCategories: []string{"dangerous"}, handler: keysCommand})
+	registerCommand(&command{name: "dbsize", arity: 1, flags: flagReadonly | flagFast, group: "server", since: "1.0.0",
+		summary: "Returns the number of keys in the database.", complexity: "O(1)", aclCategories: []string{"keyspace"}, handler: dbsizeCommand})
+
+	registerCommand(&command{name: "expire", arity: -3, flags: flagWrite | flagFast, firstKey: 1, lastKey: 1, step: 1, group: "generic", since: "1.0.0",
+		summary: "Sets the expiration time of a key in seconds.", complexity: "O(1)", handler: expireCommand})
+	registerCommand(&command{name: "pexpire", arity: -3, flags: flagWrite | flagFast, firstKey: 1, lastKey: 1, step: 1, group: "generic", since: "2.6.0",
+		summary: "Sets the expiration time of a key in milliseconds.", complexity: "O(1)", handler: pexpireCommand})
+	registerCommand(&command{name: "expireat", arity: -3, flags: flagWrite | flagFast, firstKey: 1, lastKey: 1, step: 1, group: "generic", since: "1.2.0",
+		summary: "Sets the expiration time of a key to a Unix timestamp.", complexity: "O(1)", handler: expireatCommand})
+	registerCommand(&command{name: "pexpireat", arity: -3, flags: flagWrite | flagFast, firstKey: 1, lastKey: 1, step: 1, group: "generic", since: "2.6.0",
+		summary: "Sets the expiration time of a key to a Unix milliseconds timestamp.", complexity: "O(1)", handler: pexpireatCommand})
+	registerCommand(&command{name: "ttl", arity: 2, flags: flagReadonly | flagFast, firstKey: 1, lastKey: 1, step: 1, group: "generic", since: "1.0.0",
+		summary: "Returns the expiration time in seconds of a key.", complexity: "O(1)", handler: ttlCommand})
+	registerCommand(&command{name: "pttl", arity: 2, flags: flagReadonly | flagFast, firstKey: 1, lastKey: 1, step: 1, group: "generic", since: "2.6.0",
+		summary: "Returns the expiration time in milliseconds of a key.", complexity: "O(1)", handler: pttlCommand})
+	registerCommand(&command{name: "expiretime", arity: 2, flags: flagReadonly | flagFast, firstKey: 1, lastKey: 1, step: 1, group: "generic", since: "7.0.0",
+		summary: "Returns the expiration time of a key as a Unix timestamp.", complexity: "O(1)", handler: expiretimeCommand})
+	registerCommand(&command{name: "pexpiretime", arity: 2, flags: flagReadonly | flagFast, firstKey: 1, lastKey: 1, step: 1, group: "generic", since: "7.0.0",
+		summary: "Returns the expiration time of a key as a Unix milliseconds timestamp.", complexity: "O(1)", handler: pexpiretimeCommand})
+	registerCommand(&command{name: "persist", arity: 2, flags: flagWrite | flagFast, firstKey: 1, lastKey: 1, step: 1, group: "generic", since: "2.2.0",
+		summary: "Removes the expiration time of a key.", complexity: "O(1)", handler: persistCommand})
+
+	registerCommand(&command{name: "move", arity: 3, flags: flagWrite | flagFast, firstKey: 1, lastKey: 1, step: 1, keyFlags: []int{keyRead | keyWrite}, group: "generic", since: "1.0.0",
+		summary: "Moves a key to another database.", complexity: "O(1)", handler: moveCommand})
+	registerCommand(&command{name: "swapdb", arity: 3, flags: flagWrite | flagFast, group: "server", since: "4.0.0",
+		summary: "Swaps two Redis databases.", complexity: "O(N) where N is the count of clients watching or blocking on keys from both databases.",
+		aclCategories: []string{"keyspace", "dangerous"}, handler: swapdbCommand})
+	registerCommand(&command{name: "flushdb", arity: -1, flags: flagWrite, group: "server", since: "1.0.0",
+		summary: "Remove all keys from the current database.", complexity: "O(N) where N is the number of keys in the selected database",
+		aclCategories: []string{"keyspace", "dangerous"}, handler: flushdbCommand})
+	registerCommand(&command{name: "flushall", arity: -1, flags: flagWrite, group: "server", since: "1.0.0",
+		summary: "Removes all keys from all databases.", complexity: "O(N) where N is the total number of keys in all databases",
+		aclCategories: []string{"keyspace", "dangerous"}, handler: flushallCommand})
+
+	registerCommand(&command{name: "lpush", arity: -3, flags: flagWrite | flagFast, firstKey: 1, lastKey: 1, step: 1, group: "list", since: "1.0.0",
+		summary: "Prepends one or more elements to a list. Creates the key if it doesn't exist.", complexity: "O(1) for each element added", handler: lpushCommand})
+	registerCommand(&command{name: "rpush", arity: -3, flags: flagWrite | flagFast, firstKey: 1, lastKey: 1, step: 1, group: "list", since: "1.0.0",
+		summary: "Appends one or more elements to a list. Creates the key if it doesn't exist.", complexity: "O(1) for each element added", handler: rpushCommand})
+	registerCommand(&command{name: "lpop", arity: 2, flags: flagWrite | flagFast, firstKey: 1, lastKey: 1, step: 1, keyFlags: []int{keyRead | keyWrite}, group: "list", since: "1.0.0",
+		summary: "Returns the first element of a list after removing it.", complexity: "O(1)", handler: lpopCommand})
+	registerCommand(&command{name: "rpop", arity: 2, flags: flagWrite | flagFast, firstKey: 1, lastKey: 1, step: 1, keyFlags: []int{keyRead | keyWrite}, group: "list", since: "1.0.0",
+		summary: "Returns and removes the last element of a list.", complexity: "O(1)", handler: rpopCommand})
+
+	registerCommand(&command{name: "hset", arity: 4, flags: flagWrite | flagFast, firstKey: 1, lastKey: 1, step: 1, group: "hash", since: "2.0.0",
+		summary: "Creates or modifies the value of a field in a hash.", complexity: "O(1)", handler: hsetCommand})
+	registerCommand(&command{name: "hget", arity: 3, flags: flagReadonly | flagFast, firstKey: 1, lastKey: 1, step: 1, group: "hash", since: "2.0.0",
+		summary: "Returns the value of a field in a hash.", complexity: "O(1)", handler: hgetCommand})
+	registerCommand(&command{name: "hscan", arity: -3, flags: flagReadonly, firstKey: 1, lastKey: 1, step: 1, group: "hash", since: "2.8.0",
+		summary: "Iterates over fields and values of a hash.", complexity: "O(COUNT) for every call. O(N) for a complete iteration, where N is the number of fields in the hash.",
+		handler: hscanCommand})
+
+	registerCommand(&command{name: "sadd", arity: -3, flags: flagWrite | flagFast, firstKey: 1, lastKey: 1, step: 1, group: "set", since: "1.0.0",
+		summary: "Adds one or more members to a set. Creates the key if it doesn't exist.", complexity: "O(1) for each element added", handler: saddCommand})
+	registerCommand(&command{name: "srem", arity: -3, flags: flagWrite | flagFast, firstKey: 1, lastKey: 1, step: 1, group: "set", since: "1.0.0",
+		summary: "Removes one or more members from a set.", complexity: "O(N) where N is the number of members to be removed", handler: sremCommand})
+	registerCommand(&command{name: "smembers", arity: 2, flags: flagReadonly, firstKey: 1, lastKey: 1, step: 1, group: "set", since: "1.0.0",
+		summary: "Returns all members of a set.", complexity: "O(N) where N is the set cardinality", handler: smembersCommand})
+	registerCommand(&command{name: "sscan", arity: -3, flags: flagReadonly, firstKey: 1, lastKey: 1, step: 1, group: "set", since: "2.8.0",
+		summary: "Iterates over members of a set.", complexity: "O(COUNT) for every call. O(N) for a complete iteration, where N is the set cardinality.",
+		handler: sscanCommand})
+}
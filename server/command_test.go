@@ -0,0 +1,190 @@
+package server
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCommandTable(t *testing.T) {
+	want := []struct {
+		name                           string
+		arity, firstKey, lastKey, step int
+	}{
+		{"acl", -2, 0, 0, 0},
+		{"acl|cat", -2, 0, 0, 0},
+		{"acl|deluser", -3, 0, 0, 0},
+		{"acl|getuser", 3, 0, 0, 0},
+		{"acl|help", 2, 0, 0, 0},
+		{"acl|list", 2, 0, 0, 0},
+		{"acl|load", 2, 0, 0, 0},
+		{"acl|log", -2, 0, 0, 0},
+		{"acl|save", 2, 0, 0, 0},
+		{"acl|setuser", -3, 0, 0, 0},
+		{"acl|users", 2, 0, 0, 0},
+		{"acl|whoami", 2, 0, 0, 0},
+		{"auth", -2, 0, 0, 0},
+		{"client", -2, 0, 0, 0},
+		{"client|getname", 2, 0, 0, 0},
+		{"client|help", 2, 0, 0, 0},
+		{"client|id", 2, 0, 0, 0},
+		{"client|info", 2, 0, 0, 0},
+		{"client|kill", -3, 0, 0, 0},
+		{"client|list", -2, 0, 0, 0},
+		{"client|pause", -3, 0, 0, 0},
+		{"client|reply", 3, 0, 0, 0},
+		{"client|setname", 3, 0, 0, 0},
+		{"client|unpause", 2, 0, 0, 0},
+		{"command", -1, 0, 0, 0},
+		{"command|count", 2, 0, 0, 0},
+		{"command|docs", -2, 0, 0, 0},
+		{"command|getkeys", -3, 0, 0, 0},
+		{"command|help", 2, 0, 0, 0},
+		{"command|info", -2, 0, 0, 0},
+		{"config", -2, 0, 0, 0},
+		{"config|get", -3, 0, 0, 0},
+		{"config|help", 2, 0, 0, 0},
+		{"config|resetstat", 2, 0, 0, 0},
+		{"config|rewrite", 2, 0, 0, 0},
+		{"config|set", -4, 0, 0, 0},
+		{"copy", -3, 1, 2, 1},
+		{"dbsize", 1, 0, 0, 0},
+		{"del", -2, 1, -1, 1},
+		{"exists", -2, 1, -1, 1},
+		{"expire", -3, 1, 1, 1},
+		{"expireat", -3, 1, 1, 1},
+		{"expiretime", 2, 1, 1, 1},
+		{"flushall", -1, 0, 0, 0},
+		{"flushdb", -1, 0, 0, 0},
+		{"get", 2, 1, 1, 1},
+		{"hello", -1, 0, 0, 0},
+		{"hget", 3, 1, 1, 1},
+		{"hscan", -3, 1, 1, 1},
+		{"hset", 4, 1, 1, 1},
+		{"info", -1, 0, 0, 0},
+		{"keys", 2, 0, 0, 0},
+		{"latency", -2, 0, 0, 0},
+		{"latency|doctor", 2, 0, 0, 0},
+		{"latency|help", 2, 0, 0, 0},
+		{"latency|history", 3, 0, 0, 0},
+		{"latency|latest", 2, 0, 0, 0},
+		{"latency|reset", -2, 0, 0, 0},
+		{"lpop", 2, 1, 1, 1},
+		{"lpush", -3, 1, 1, 1},
+		{"monitor", 1, 0, 0, 0},
+		{"move", 3, 1, 1, 1},
+		{"persist", 2, 1, 1, 1},
+		{"pexpire", -3, 1, 1, 1},
+		{"pexpireat", -3, 1, 1, 1},
+		{"pexpiretime", 2, 1, 1, 1},
+		{"pttl", 2, 1, 1, 1},
+		{"quit", -1, 0, 0, 0},
+		{"randomkey", 1, 0, 0, 0},
+		{"rename", 3, 1, 2, 1},
+		{"renamenx", 3, 1, 2, 1},
+		{"reset", 1, 0, 0, 0},
+		{"rpop", 2, 1, 1, 1},
+		{"rpush", -3, 1, 1, 1},
+		{"sadd", -3, 1, 1, 1},
+		{"scan", -2, 0, 0, 0},
+		{"select", 2, 0, 0, 0},
+		{"set", -3, 1, 1, 1},
+		{"shutdown", -1, 0, 0, 0},
+		{"slowlog", -2, 0, 0, 0},
+		{"slowlog|get", -2, 0, 0, 0},
+		{"slowlog|help", 2, 0, 0, 0},
+		{"slowlog|len", 2, 0, 0, 0},
+		{"slowlog|reset", 2, 0, 0, 0},
+		{"smembers", 2, 1, 1, 1},
+		{"srem", -3, 1, 1, 1},
+		{"sscan", -3, 1, 1, 1},
+		{"swapdb", 3, 0, 0, 0},
+		{"touch", -2, 1, -1, 1},
+		{"ttl", 2, 1, 1, 1},
+		{"type", 2, 1, 1, 1},
+	}
+
+	seen := map[string]bool{}
+	for _, tc := range want {
+		seen[tc.name] = true
+		cmd := lookupCommandByName(tc.name)
+		if cmd == nil {
+			t.Errorf("%s is not registered", tc.name)
+			continue
+		}
+		if cmd.arity != tc.arity || cmd.firstKey != tc.firstKey || cmd.lastKey != tc.lastKey || cmd.step != tc.step {
+			t.Errorf("%s: arity %d, keys %d %d %d, want arity %d, keys %d %d %d", tc.name,
+				cmd.arity, cmd.firstKey, cmd.lastKey, cmd.step, tc.arity, tc.firstKey, tc.lastKey, tc.step)
+		}
+	}
+	for _, cmd := range commandTable {
+		for _, c := range append([]*command{cmd}, sortedCommands(cmd.subcommands)...) {
+			if !seen[c.fullName()] {
+				t.Errorf("%s is missing from the expected table", c.fullName())
+			}
+		}
+	}
+}
+
+func TestCommandGetKeys(t *testing.T) {
+	srv := startServer(t)
+	c := dial(t, srv.Addr())
+
+	for _, tc := range []struct {
+		argv []string
+		want []string
+	}{
+		{[]string{"GET", "k"}, []string{"k"}},
+		{[]string{"SET", "k", "v", "10"}, []string{"k"}},
+		{[]string{"DEL", "a", "b", "c"}, []string{"a", "b", "c"}},
+		{[]string{"COPY", "src", "dst", "REPLACE"}, []string{"src", "dst"}},
+		{[]string{"RENAME", "from", "to"}, []string{"from", "to"}},
+		{[]string{"HSET", "h", "field", "value"}, []string{"h"}},
+	} {
+		reply := c.do(append([]string{"COMMAND", "GETKEYS"}, tc.argv...)...)
+		var got []string
+		for _, elem := range reply.Elems {
+			got = append(got, elem.Str)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("COMMAND GETKEYS %q = %+v, want %q", tc.argv, reply, tc.want)
+		}
+	}
+
+	for argv, want := range map[string]string{
+		"NOSUCHCOMMAND k": "ERR Invalid command specified",
+		"GET":             "ERR Invalid number of arguments specified for command",
+		"INFO server":     "ERR The command has no key arguments",
+	} {
+		if reply := c.do(append([]string{"COMMAND", "GETKEYS"}, strings.Fields(argv)...)...); reply.Str != want {
+			t.Errorf("COMMAND GETKEYS %s replied %+v, want %q", argv, reply, want)
+		}
+	}
+}
+
+func TestWrongNumberOfArguments(t *testing.T) {
+	srv := startServer(t)
+	c := dial(t, srv.Addr())
+
+	for _, cmd := range sortedCommands(commandTable) {
+		for _, cmd := range append([]*command{cmd}, sortedCommands(cmd.subcommands)...) {
+			// One argument too many for a fixed arity, one too few for a
+			// minimum, skipping minimums the command name alone meets.
+			n := cmd.arity + 1
+			if cmd.arity < 0 {
+				n = -cmd.arity - 1
+			}
+			if n <= cmd.argOffset() {
+				continue
+			}
+			argv := strings.Split(cmd.fullName(), "|")
+			for len(argv) < n {
+				argv = append(argv, "x")
+			}
+			want := "ERR wrong number of arguments for '" + strings.ToUpper(cmd.fullName()) + "' command"
+			if reply := c.do(argv...); reply.Str != want {
+				t.Errorf("%q replied %+v, want %q", argv, reply, want)
+			}
+		}
+	}
+}
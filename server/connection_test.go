@@ -0,0 +1,52 @@
+package server
+
+import (
+	"context"
+	"mini-redis/protocol"
+	"strings"
+	"testing"
+)
+
+func TestHelloSwitchesProtocol(t *testing.T) {
+	srv, err := New(WithAddr("127.0.0.1:0"), WithLogger(quietLogger(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	serve(context.Background(), srv)
+	t.Cleanup(func() { srv.Shutdown(context.Background()) })
+	c := dial(t, srv.Addr())
+
+	reply := c.do("HELLO", "3")
+	if reply.Type != protocol.Map {
+		t.Fatalf("HELLO 3 replied %+v, want a map", reply)
+	}
+	info := map[string]protocol.Value{}
+	for i := 0; i+1 < len(reply.Elems); i += 2 {
+		info[reply.Elems[i].Str] = reply.Elems[i+1]
+	}
+	if info["server"].Str != serverName || info["proto"].Int != 3 || info["mode"].Str != "standalone" {
+		t.Errorf("HELLO 3 server info = %+v", info)
+	}
+	if reply := c.do("GET", "missing"); reply.Type != protocol.Null {
+		t.Errorf("GET over RESP3 replied %+v, want a RESP3 null", reply)
+	}
+
+	if reply := c.do("HELLO", "2"); reply.Type != protocol.Array {
+		t.Errorf("HELLO 2 replied %+v, want a flat array", reply)
+	}
+	if reply := c.do("GET", "missing"); reply.Type != protocol.BulkString || !reply.IsNull {
+		t.Errorf("GET over RESP2 replied %+v, want a null bulk string", reply)
+	}
+
+	for _, version := range []string{"1", "4", "three"} {
+		reply := c.do("HELLO", version)
+		if reply.Type != protocol.Error {
+			t.Errorf("HELLO %s replied %+v, want an error", version, reply)
+		} else if version != "three" && !strings.HasPrefix(reply.Str, "NOPROTO") {
+			t.Errorf("HELLO %s replied %q, want NOPROTO", version, reply.Str)
+		}
+	}
+	if reply := c.do("GET", "missing"); reply.Type != protocol.BulkString {
+		t.Errorf("a rejected HELLO changed the protocol: %+v", reply)
+	}
+}
@@ -0,0 +1,61 @@
+package server
+
+import (
+	"math"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestTTL(t *testing.T) {
+	srv := startServer(t)
+	c := dial(t, srv.Addr())
+
+	c.do("SET", "plain", "v")
+	c.do("SET", "k", "v")
+	c.do("EXPIRE", "k", "100")
+	for _, tc := range []struct {
+		argv     []string
+		min, max int64
+	}{
+		{[]string{"TTL", "missing"}, -2, -2},
+		{[]string{"PTTL", "missing"}, -2, -2},
+		{[]string{"TTL", "plain"}, -1, -1},
+		{[]string{"PTTL", "plain"}, -1, -1},
+		{[]string{"TTL", "k"}, 100, 100},
+		{[]string{"PTTL", "k"}, 99000, 100000},
+	} {
+		if reply := c.do(tc.argv...); reply.Int < tc.min || reply.Int > tc.max {
+			t.Errorf("%q replied %+v, want %d to %d", tc.argv, reply, tc.min, tc.max)
+		}
+	}
+}
+
+// TestTTLBeyondDurationRange checks expiries further away than the ~292
+// years a time.Duration holds, where time.Until saturates.
+func TestTTLBeyondDurationRange(t *testing.T) {
+	srv := startServer(t)
+	c := dial(t, srv.Addr())
+
+	c.do("SET", "k", "v")
+	if reply := c.do("PEXPIREAT", "k", strconv.FormatInt(math.MaxInt64, 10)); reply.Int != 1 {
+		t.Fatalf("PEXPIREAT replied %+v", reply)
+	}
+	before := time.Now().UnixMilli()
+	pttl := c.do("PTTL", "k").Int
+	ttl := c.do("TTL", "k").Int
+	after := time.Now().UnixMilli()
+
+	if pttl < math.MaxInt64-after || pttl > math.MaxInt64-before {
+		t.Errorf("PTTL = %d, want about %d", pttl, math.MaxInt64-before)
+	}
+	if ttl < (math.MaxInt64-after)/1000 || ttl > (math.MaxInt64-before)/1000+1 {
+		t.Errorf("TTL = %d, want about %d", ttl, (math.MaxInt64-before)/1000)
+	}
+	if reply := c.do("PEXPIRETIME", "k"); reply.Int != math.MaxInt64 {
+		t.Errorf("PEXPIRETIME = %+v", reply)
+	}
+	if reply := c.do("EXPIRETIME", "k"); reply.Int != math.MaxInt64/1000+1 {
+		t.Errorf("EXPIRETIME = %+v, want %d", reply, int64(math.MaxInt64/1000+1))
+	}
+}
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func infoHeaders(info string) []string {
+	var headers []string
+	for _, line := range strings.Split(info, "\r\n") {
+		if strings.HasPrefix(line, "# ") {
+			headers = append(headers, line[2:])
+		}
+	}
+	return headers
+}
+
+func TestInfoSections(t *testing.T) {
+	srv := startServer(t)
+	c := dial(t, srv.Addr())
+	// Command stats live in the shared command table.
+	c.do("CONFIG", "RESETSTAT")
+	c.do("SET", "k", "v")
+
+	defaults := []string{"Server", "Clients", "Memory", "Persistence", "Stats", "Keyspace"}
+	everything := []string{"Server", "Clients", "Memory", "Persistence", "Stats", "Commandstats", "Keyspace"}
+	for _, tc := range []struct {
+		args []string
+		want []string
+	}{
+		{nil, defaults},
+		{[]string{"default"}, defaults},
+		{[]string{"all"}, everything},
+		{[]string{"everything"}, everything},
+		{[]string{"clients"}, []string{"Clients"}},
+		{[]string{"KEYSPACE", "Server"}, []string{"Server", "Keyspace"}},
+		{[]string{"commandstats"}, []string{"Commandstats"}},
+		{[]string{"default", "commandstats"}, everything},
+		{[]string{"nosuchsection"}, nil},
+	} {
+		info := c.do(append([]string{"INFO"}, tc.args...)...).Str
+		if got := infoHeaders(info); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("INFO %s sections = %q, want %q", strings.Join(tc.args, " "), got, tc.want)
+		}
+	}
+
+	if info := c.do("INFO", "clients").Str; !strings.Contains(info, "connected_clients:1\r\n") {
+		t.Errorf("INFO clients = %q", info)
+	}
+	if info := c.do("INFO", "commandstats").Str; !strings.Contains(info, "cmdstat_set:calls=1,") {
+		t.Errorf("INFO commandstats = %q", info)
+	}
+}
+
+func TestInfoKeyspace(t *testing.T) {
+	srv := startServer(t)
+	c := dial(t, srv.Addr())
+
+	if info := c.do("INFO", "keyspace").Str; info != "# Keyspace\r\n" {
+		t.Errorf("INFO keyspace of an empty server = %q", info)
+	}
+	c.do("SET", "a", "1")
+	c.do("SET", "b", "2", "100")
+	c.do("SET", "c", "3")
+	c.do("EXPIRE", "c", "100")
+	c.do("SELECT", "2")
+	c.do("SET", "d", "4")
+
+	info := c.do("INFO", "keyspace").Str
+	lines := regexp.MustCompile(`(?m)^db\d+:.*$`).FindAllString(info, -1)
+	if len(lines) != 2 {
+		t.Fatalf("INFO keyspace = %q, want lines for db0 and db2", info)
+	}
+	if !regexp.MustCompile(`^db0:keys=3,expires=2,avg_ttl=\d+\r$`).MatchString(lines[0]) {
+		t.Errorf("db0 line = %q", lines[0])
+	}
+	if lines[1] != "db2:keys=1,expires=0,avg_ttl=0\r" {
+		t.Errorf("db2 line = %q", lines[1])
+	}
+}
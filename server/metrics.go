@@ -0,0 +1,112 @@
+package server
+
+import (
+	"errors"
+	"mini-redis/metrics"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// serveMetrics serves /metrics on listener until the listener is closed.
+func (s *Server) serveMetrics(listener net.Listener) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", s.metricsHandler)
+	httpServer := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	if err := httpServer.Serve(listener); err != nil && !errors.Is(err, net.ErrClosed) {
+		s.log.Warning("Error serving metrics", "err", err)
+	}
+}
+
+func (s *Server) metricsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", metrics.ContentType)
+	mw := metrics.NewWriter(w)
+	s.writeMetrics(mw)
+	if err := mw.Flush(); err != nil {
+		s.log.Verbose("Error writing metrics", "addr", r.RemoteAddr, "err", err)
+	}
+}
+
+// writeMetrics writes every metric family. The names follow the
+// conventions of redis_exporter so existing dashboards mostly apply.
+func (s *Server) writeMetrics(w *metrics.Writer) {
+	s.mu.Lock()
+	connected := len(s.clients)
+	s.mu.Unlock()
+
+	w.Family("redis_uptime_in_seconds", "gauge", "Seconds since the server started.")
+	w.Sample("redis_uptime_in_seconds", time.Since(s.startTime).Seconds())
+	w.Family("redis_connected_clients", "gauge", "Number of client connections.")
+	w.Sample("redis_connected_clients", float64(connected))
+	w.Family("redis_connections_received_total", "counter", "Connections accepted by the server.")
+	w.Sample("redis_connections_received_total", float64(atomic.LoadInt64(&s.stats.totalConnections)))
+	w.Family("redis_commands_processed_total", "counter", "Commands processed by the server.")
+	w.Sample("redis_commands_processed_total", float64(atomic.LoadInt64(&s.stats.totalCommands)))
+	w.Family("redis_net_input_bytes_total", "counter", "Bytes read from clients.")
+	w.Sample("redis_net_input_bytes_total", float64(atomic.LoadInt64(&s.stats.netInputBytes)))
+	w.Family("redis_net_output_bytes_total", "counter", "Bytes written to clients.")
+	w.Sample("redis_net_output_bytes_total", float64(atomic.LoadInt64(&s.stats.netOutputBytes)))
+
+	commands := []*command{}
+	for _, cmd := range sortedCommands(commandTable) {
+		if cmd.subcommands == nil {
+			commands = append(commands, cmd)
+			continue
+		}
+		commands = append(commands, sortedCommands(cmd.subcommands)...)
+	}
+	w.Family("redis_commands_total", "counter", "Calls per command.")
+	for _, cmd := range commands {
+		w.Sample("redis_commands_total", float64(atomic.LoadInt64(&cmd.stats.calls)), "cmd", cmd.fullName())
+	}
+	w.Family("redis_commands_rejected_calls_total", "counter", "Calls per command refused before running, for example by ACLs.")
+	for _, cmd := range commands {
+		w.Sample("redis_commands_rejected_calls_total", float64(atomic.LoadInt64(&cmd.stats.rejectedCalls)), "cmd", cmd.fullName())
+	}
+	w.Family("redis_commands_failed_calls_total", "counter", "Calls per command that replied with an error.")
+	for _, cmd := range commands {
+		w.Sample("redis_commands_failed_calls_total", float64(atomic.LoadInt64(&cmd.stats.failedCalls)), "cmd", cmd.fullName())
+	}
+	w.Family("redis_commands_duration_seconds", "histogram", "Time spent running each command.")
+	for _, cmd := range commands {
+		w.Histogram("redis_commands_duration_seconds", &cmd.stats.latency, "cmd", cmd.fullName())
+	}
+
+	db := s.dbs.Stats()
+	w.Family("redis_keyspace_hits_total", "counter", "Lookups of keys that existed.")
+	w.Sample("redis_keyspace_hits_total", float64(db.KeyspaceHits))
+	w.Family("redis_keyspace_misses_total", "counter", "Lookups of keys that did not exist.")
+	w.Sample("redis_keyspace_misses_total", float64(db.KeyspaceMisses))
+	w.Family("redis_expired_keys_total", "counter", "Keys deleted because their TTL passed.")
+	w.Sample("redis_expired_keys_total", float64(db.ExpiredKeys))
+	// There is no maxmemory policy yet, so nothing is ever evicted; the
+	// series exists so dashboards and alerts need no special case.
+	w.Family("redis_evicted_keys_total", "counter", "Keys evicted to stay under maxmemory.")
+	w.Sample("redis_evicted_keys_total", 0)
+
+	counts := s.dbs.KeyCounts()
+	w.Family("redis_keys", "gauge", "Number of keys of each type.")
+	w.Sample("redis_keys", float64(counts.Strings), "type", "string")
+	w.Sample("redis_keys", float64(counts.Lists), "type", "list")
+	w.Sample("redis_keys", float64(counts.Hashes), "type", "hash")
+	w.Sample("redis_keys", float64(counts.Sets), "type", "set")
+
+	s.saves.mu.Lock()
+	inProgress, lastSave, lastOK, lastDuration := s.saves.inProgress, s.saves.lastSave, s.saves.lastOK, s.saves.lastDuration
+	s.saves.mu.Unlock()
+	w.Family("redis_rdb_changes_since_last_save", "gauge", "Write commands since the last successful snapshot.")
+	w.Sample("redis_rdb_changes_since_last_save", float64(atomic.LoadInt64(&s.dirty)))
+	w.Family("redis_rdb_bgsave_in_progress", "gauge", "Whether a snapshot is being saved.")
+	w.Sample("redis_rdb_bgsave_in_progress", float64(boolInt(inProgress)))
+	w.Family("redis_rdb_last_bgsave_status", "gauge", "Whether the last snapshot succeeded.")
+	w.Sample("redis_rdb_last_bgsave_status", float64(boolInt(lastOK)))
+	w.Family("redis_rdb_last_bgsave_duration_sec", "gauge", "Duration of the last snapshot.")
+	w.Sample("redis_rdb_last_bgsave_duration_sec", lastDuration.Seconds())
+	w.Family("redis_rdb_last_save_timestamp_seconds", "gauge", "Unix time of the last snapshot saved or loaded, 0 if none.")
+	w.Sample("redis_rdb_last_save_timestamp_seconds", float64(unixTime(lastSave)))
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"context"
+	"mini-redis/metrics"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// lastSaveMetric returns the value of redis_rdb_last_save_timestamp_seconds.
+func lastSaveMetric(t *testing.T, srv *Server) int64 {
+	t.Helper()
+	var sb strings.Builder
+	w := metrics.NewWriter(&sb)
+	srv.writeMetrics(w)
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(sb.String(), "\n") {
+		if value, ok := strings.CutPrefix(line, "redis_rdb_last_save_timestamp_seconds "); ok {
+			v, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return int64(v)
+		}
+	}
+	t.Fatal("redis_rdb_last_save_timestamp_seconds is missing")
+	return 0
+}
+
+func TestLastSaveTimestamp(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dump.json")
+	srv, err := New(WithAddr("127.0.0.1:0"), WithPersistence(path, time.Hour), WithLogger(quietLogger(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	errc := serve(context.Background(), srv)
+	c := dial(t, srv.Addr())
+	if got := lastSaveMetric(t, srv); got != 0 {
+		t.Errorf("last save before any save = %d, want 0", got)
+	}
+	if info := c.do("INFO", "persistence").Str; !strings.Contains(info, "rdb_last_save_time:0\r\n") {
+		t.Errorf("INFO persistence before any save = %q", info)
+	}
+
+	c.do("SET", "k", "v")
+	before := time.Now().Unix()
+	if err := srv.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	<-errc
+	if got := lastSaveMetric(t, srv); got < before || got > time.Now().Unix() {
+		t.Errorf("last save after saving = %d, want about %d", got, before)
+	}
+
+	// Loading the snapshot counts as a save made when the file was written.
+	time.Sleep(1100 * time.Millisecond)
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := New(WithPersistence(path, time.Hour), WithLogger(quietLogger(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := lastSaveMetric(t, loaded); got != info.ModTime().Unix() {
+		t.Errorf("last save after loading = %d, want the snapshot's mtime %d", got, info.ModTime().Unix())
+	}
+}
@@ -0,0 +1,139 @@
+package server
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRedactedArgs(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		argv []string
+		want []string
+	}{
+		{"auth", []string{"AUTH", "secret"}, []string{"AUTH", "(redacted)"}},
+		{"auth", []string{"AUTH", "alice", "secret"}, []string{"AUTH", "(redacted)", "(redacted)"}},
+		{"hello", []string{"HELLO", "3", "AUTH", "alice", "secret", "SETNAME", "app"},
+			[]string{"HELLO", "3", "AUTH", "(redacted)", "(redacted)", "SETNAME", "app"}},
+		{"acl|setuser", []string{"ACL", "SETUSER", "alice", "on", ">secret", "<old", "~*"},
+			[]string{"ACL", "SETUSER", "alice", "on", "(redacted)", "(redacted)", "~*"}},
+		{"get", []string{"GET", ">secret"}, []string{"GET", ">secret"}},
+	} {
+		cmd := commandTable[tc.name]
+		if parent, sub, ok := strings.Cut(tc.name, "|"); ok {
+			cmd = commandTable[parent].subcommands[sub]
+		}
+		if got := redactedArgs(cmd, tc.argv); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("redactedArgs(%q) = %q, want %q", tc.argv, got, tc.want)
+		}
+	}
+}
+
+func TestMonitorFeed(t *testing.T) {
+	srv, err := New(WithAddr("127.0.0.1:0"), WithLogger(quietLogger(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	serve(context.Background(), srv)
+	t.Cleanup(func() { srv.Shutdown(context.Background()) })
+	monitor, c := dial(t, srv.Addr()), dial(t, srv.Addr())
+
+	if reply := monitor.do("MONITOR"); reply.Str != "OK" {
+		t.Fatalf("MONITOR replied %+v", reply)
+	}
+	c.do("AUTH", "secret")
+	c.do("HELLO", "2", "AUTH", "default", "secret")
+	c.do("CONFIG", "GET", "port")
+	c.do("SET", "k", "a \"b\"\n")
+
+	monitor.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var lines []string
+	for len(lines) == 0 || !strings.Contains(lines[len(lines)-1], `"SET"`) {
+		lines = append(lines, monitor.read().Str)
+	}
+	if len(lines) != 3 {
+		t.Fatalf("monitor saw %q, want AUTH, HELLO and SET", lines)
+	}
+	for _, line := range lines {
+		if strings.Contains(line, "secret") {
+			t.Errorf("password leaked to MONITOR: %q", line)
+		}
+		if strings.Contains(strings.ToLower(line), "config") {
+			t.Errorf("admin command fed to MONITOR: %q", line)
+		}
+	}
+	if !strings.HasSuffix(lines[0], `"AUTH" "(redacted)"`) {
+		t.Errorf("AUTH line = %q", lines[0])
+	}
+	if !strings.Contains(lines[2], `[0 `) || !strings.HasSuffix(lines[2], `"SET" "k" "a \"b\"\n"`) {
+		t.Errorf("SET line = %q", lines[2])
+	}
+}
+
+func TestQuoteArg(t *testing.T) {
+	for arg, want := range map[string]string{
+		"plain":          `"plain"`,
+		"":               `""`,
+		`a"b\c`:          `"a\"b\\c"`,
+		"\r\n\t\a\b":     `"\r\n\t\a\b"`,
+		"\x00\x7f\xff é": `"\x00\x7f\xff \xc3\xa9"`,
+	} {
+		if got := quoteArg(arg); got != want {
+			t.Errorf("quoteArg(%q) = %s, want %s", arg, got, want)
+		}
+	}
+}
+
+func TestMonitorBacklogDisconnects(t *testing.T) {
+	srv, err := New(WithLogger(quietLogger(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	newFeed := func() (*monitorFeed, net.Conn) {
+		clientSide, serverSide := net.Pipe()
+		t.Cleanup(func() { clientSide.Close() })
+		feed := &monitorFeed{
+			c:     &client{srv: srv, conn: serverSide},
+			lines: make(chan string, monitorMaxLines),
+			stop:  make(chan struct{}),
+		}
+		srv.addMonitor(feed)
+		return feed, clientSide
+	}
+	disconnected := func(feed *monitorFeed, conn net.Conn) bool {
+		select {
+		case <-feed.stop:
+		default:
+			return false
+		}
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		_, err := conn.Read(make([]byte, 1))
+		return err != nil && !strings.Contains(err.Error(), "deadline")
+	}
+
+	// Nothing drains the feeds, as with a client that stopped reading.
+	feed, conn := newFeed()
+	for i := 0; i < monitorMaxLines; i++ {
+		feed.send("line")
+	}
+	if disconnected(feed, conn) {
+		t.Fatal("disconnected before the line limit")
+	}
+	feed.send("line")
+	if !disconnected(feed, conn) {
+		t.Error("not disconnected over the line limit")
+	}
+
+	feed, conn = newFeed()
+	feed.send(strings.Repeat("x", monitorMaxBytes+1))
+	if !disconnected(feed, conn) {
+		t.Error("not disconnected over the byte limit")
+	}
+	if feeds := srv.monitors.Load(); feeds != nil && len(*feeds) != 0 {
+		t.Errorf("%d disconnected monitors still registered", len(*feeds))
+	}
+}
@@ -0,0 +1,259 @@
+package server
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"mini-redis/acl"
+	"mini-redis/protocol"
+	"mini-redis/store"
+	"mini-redis/tlsconfig"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	replyOn = iota
+	replyOff
+	replySkip
+)
+
+type client struct {
+	id   int64
+	conn net.Conn
+	srv  *Server
+	// db is the selected database; only the client's own goroutine
+	// changes it, under mu together with dbIndex.
+	db        *store.KeyValueStore
+	reader    *bufio.Reader
+	w         *protocol.Writer
+	createdAt time.Time
+
+	replyMode       int
+	closeAfterReply bool
+
+	// wmu serialises use of w between the command loop and, once the
+	// client runs MONITOR, the goroutine streaming the feed to it.
+	wmu sync.Mutex
+
+	// mu guards the fields that CLIENT LIST and CLIENT KILL read from other
+	// connections' goroutines.
+	mu              sync.Mutex
+	name            string
+	user            string
+	authenticated   bool
+	resp            int
+	dbIndex         int
+	lastInteraction time.Time
+	lastCommand     string
+	qbuf            int
+	obuf            int
+	// monitor is only set by the client's own goroutine, so that goroutine
+	// may read it without holding mu.
+	monitor *monitorFeed
+}
+
+func (s *Server) handleConnection(conn net.Conn) {
+	defer conn.Close()
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		if err := tlsconfig.Handshake(tlsConn); err != nil {
+			s.log.Verbose("Error accepting a TLS connection", "addr", conn.RemoteAddr().String(), "err", err)
+			return
+		}
+	}
+	atomic.AddInt64(&s.stats.totalConnections, 1)
+	counted := countingConn{conn, &s.stats}
+	now := time.Now()
+	c := &client{
+		id:              atomic.AddInt64(&s.nextClientID, 1),
+		conn:            conn,
+		srv:             s,
+		db:              s.dbs.DB(0),
+		reader:          bufio.NewReader(counted),
+		w:               protocol.NewWriter(counted),
+		createdAt:       now,
+		resp:            protocol.RESP2,
+		lastInteraction: now,
+		lastCommand:     "NULL",
+		user:            acl.DefaultUser,
+	}
+	// As in Redis, connections start out authenticated as the default
+	// user unless it requires a password.
+	if u := s.acl.User(acl.DefaultUser); u != nil && u.Enabled() && u.NoPass() {
+		c.authenticated = true
+	}
+	if !s.addClient(c) {
+		return
+	}
+	defer s.removeClient(c)
+	defer c.stopMonitor()
+	s.log.Verbose("Accepted connection", "id", c.id, "addr", c.addr())
+	defer s.log.Verbose("Client closed connection", "id", c.id, "addr", c.addr())
+
+	for {
+		command, err := protocol.ParseRESPWithLimits(c.reader, s.config.Current().ProtocolLimits())
+		if err != nil {
+			// After a malformed request there is no telling where the next
+			// one starts, so reply once and drop the connection.
+			if _, ok := err.(*protocol.ProtocolError); ok {
+				c.wmu.Lock()
+				c.w.WriteError("ERR " + err.Error())
+				c.w.Flush()
+				c.wmu.Unlock()
+			}
+			return
+		}
+
+		c.wmu.Lock()
+		if len(command) > 0 {
+			atomic.AddInt64(&s.stats.totalCommands, 1)
+			executeCommand(c, command)
+		}
+
+		// Replies to pipelined commands are batched until the client has
+		// nothing left in flight.
+		if c.reader.Buffered() == 0 || c.closeAfterReply {
+			c.w.Flush()
+		}
+		c.mu.Lock()
+		c.qbuf, c.obuf = c.reader.Buffered(), c.w.Buffered()
+		c.mu.Unlock()
+		failed := c.w.Err() != nil
+		c.wmu.Unlock()
+		if failed || c.closeAfterReply {
+			return
+		}
+	}
+}
+
+// countingConn adds the bytes read and written on a connection to the
+// server's network totals.
+type countingConn struct {
+	net.Conn
+	stats *serverStats
+}
+
+func (c countingConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	atomic.AddInt64(&c.stats.netInputBytes, int64(n))
+	return n, err
+}
+
+func (c countingConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	atomic.AddInt64(&c.stats.netOutputBytes, int64(n))
+	return n, err
+}
+
+func (c *client) touch(cmd string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lastInteraction = time.Now()
+	c.lastCommand = cmd
+}
+
+func (c *client) getName() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.name
+}
+
+func (c *client) setName(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.name = name
+}
+
+func (c *client) username() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.user
+}
+
+func (c *client) isAuthenticated() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.authenticated
+}
+
+func (c *client) setUser(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.user = name
+	c.authenticated = true
+}
+
+func (c *client) setProtocol(proto int) {
+	c.w.SetProtocol(proto)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.resp = proto
+}
+
+func (c *client) selectDB(index int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.db, c.dbIndex = c.srv.dbs.DB(index), index
+}
+
+func (c *client) age() time.Duration {
+	return time.Since(c.createdAt)
+}
+
+// addr and laddr follow Redis in reporting Unix socket connections as
+// "<path>:0" on both ends.
+func (c *client) addr() string {
+	if c.conn.LocalAddr().Network() == "unix" {
+		return c.laddr()
+	}
+	return c.conn.RemoteAddr().String()
+}
+
+func (c *client) laddr() string {
+	if c.conn.LocalAddr().Network() == "unix" {
+		return c.conn.LocalAddr().String() + ":0"
+	}
+	return c.conn.LocalAddr().String()
+}
+
+// flags is called with mu held.
+func (c *client) flags() string {
+	flags := ""
+	if c.monitor != nil {
+		flags += "O"
+	}
+	if c.conn.LocalAddr().Network() == "unix" {
+		flags += "U"
+	}
+	if flags == "" {
+		return "N"
+	}
+	return flags
+}
+
+func (c *client) kind() string {
+	return "normal"
+}
+
+// info renders the client the way CLIENT LIST and CLIENT INFO show it.
+func (c *client) info() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return fmt.Sprintf("id=%d addr=%s laddr=%s name=%s age=%d idle=%d flags=%s db=%d sub=0 psub=0 multi=-1 qbuf=%d qbuf-free=%d obl=%d oll=0 omem=%d cmd=%s user=%s resp=%d",
+		c.id, c.addr(), c.laddr(), c.name,
+		int64(time.Since(c.createdAt)/time.Second), int64(time.Since(c.lastInteraction)/time.Second), c.flags(), c.dbIndex,
+		c.qbuf, c.reader.Size()-c.qbuf, c.obuf, c.obuf, c.lastCommand, c.user, c.resp)
+}
+
+// validClientName rejects names that would break the space separated
+// CLIENT LIST format.
+func validClientName(name string) bool {
+	for i := 0; i < len(name); i++ {
+		if name[i] < '!' || name[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
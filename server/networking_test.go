@@ -0,0 +1,90 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"mini-redis/protocol"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// writeCountingConn counts the writes reaching the connection, or fails
+// them all when failWrites is set.
+type writeCountingConn struct {
+	net.Conn
+	writes     int64
+	failWrites bool
+}
+
+func (c *writeCountingConn) Write(p []byte) (int, error) {
+	atomic.AddInt64(&c.writes, 1)
+	if c.failWrites {
+		return 0, errors.New("write failed")
+	}
+	return c.Conn.Write(p)
+}
+
+// handlePipe runs handleConnection on one end of a pipe and returns the
+// other end, and a channel closed when handleConnection returns.
+func handlePipe(t *testing.T, wrap func(net.Conn) net.Conn) (net.Conn, <-chan struct{}) {
+	srv, err := New(WithLogger(quietLogger(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientSide, serverSide := net.Pipe()
+	t.Cleanup(func() { clientSide.Close() })
+	done := make(chan struct{})
+	go func() {
+		srv.handleConnection(wrap(serverSide))
+		close(done)
+	}()
+	return clientSide, done
+}
+
+func TestPipelinedRepliesAreFlushedOnce(t *testing.T) {
+	counting := &writeCountingConn{}
+	conn, _ := handlePipe(t, func(c net.Conn) net.Conn {
+		counting.Conn = c
+		return counting
+	})
+
+	const n = 20
+	var batch bytes.Buffer
+	for i := 0; i < n; i++ {
+		batch.Write(protocol.Encode(protocol.NewStringArray([]string{"SET", "k", "v"}), protocol.RESP2))
+	}
+	if _, err := conn.Write(batch.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	reader := bufio.NewReader(conn)
+	for i := 0; i < n; i++ {
+		if reply, err := protocol.Decode(reader); err != nil || reply.Str != "OK" {
+			t.Fatalf("reply %d = %+v, %v", i, reply, err)
+		}
+	}
+	if writes := atomic.LoadInt64(&counting.writes); writes != 1 {
+		t.Errorf("%d pipelined replies took %d writes, want 1", n, writes)
+	}
+}
+
+func TestWriteErrorClosesConnection(t *testing.T) {
+	conn, done := handlePipe(t, func(c net.Conn) net.Conn {
+		return &writeCountingConn{Conn: c, failWrites: true}
+	})
+
+	if _, err := conn.Write(protocol.Encode(protocol.NewStringArray([]string{"SET", "k", "v"}), protocol.RESP2)); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the connection stayed open after a failed write")
+	}
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err == nil {
+		t.Error("the server end of the connection is still open")
+	}
+}
@@ -0,0 +1,784 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mini-redis/acl"
+	"mini-redis/config"
+	"mini-redis/latency"
+	"mini-redis/logging"
+	"mini-redis/slowlog"
+	"mini-redis/store"
+	"mini-redis/tlsconfig"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	serverName    = "redis"
+	serverVersion = "7.2.0"
+)
+
+// Server is a mini-redis server. Build one with New, run it with Serve or
+// ListenAndServe and stop it with Shutdown or the SHUTDOWN command.
+type Server struct {
+	config  *config.Manager
+	log     *logging.Logger
+	dbs     *store.Databases
+	stats   serverStats
+	acl     *acl.Registry
+	aclLog  acl.Log
+	slow    slowlog.Log
+	latency latency.Monitor
+	// monitors is replaced, never modified, so feeding it only costs an
+	// atomic load when nobody is monitoring.
+	monitors atomic.Pointer[[]*monitorFeed]
+
+	startTime    time.Time
+	runID        string
+	nextClientID int64
+	// dirty counts the write commands since the last successful save.
+	dirty int64
+	saves saveStats
+	// active counts commands that are executing right now; shutdown waits
+	// for it to drop to zero before saving the final snapshot.
+	active int64
+
+	tls *tlsconfig.Reloader
+	// ready is closed once Serve has tried to open its listeners, or the
+	// server is shut down.
+	ready     chan struct{}
+	readyOnce sync.Once
+
+	mu sync.Mutex
+	// addr is the WithAddr address until the first listener is opened on
+	// it, after which bind and port hold the address actually bound.
+	addr      string
+	serving   bool
+	listeners []net.Listener
+	clients   map[*client]struct{}
+	shutdown  *shutdownState
+	pause     *pauseState
+	done      chan struct{}
+}
+
+type saveStats struct {
+	mu         sync.Mutex
+	inProgress bool
+	// lastSave is when the dataset last matched the snapshot on disk, by
+	// saving or loading it, and zero if it never has.
+	lastSave     time.Time
+	lastOK       bool
+	lastDuration time.Duration
+}
+
+type serverStats struct {
+	totalConnections int64
+	totalCommands    int64
+	netInputBytes    int64
+	netOutputBytes   int64
+}
+
+func (s *serverStats) reset() {
+	atomic.StoreInt64(&s.totalConnections, 0)
+	atomic.StoreInt64(&s.totalCommands, 0)
+	atomic.StoreInt64(&s.netInputBytes, 0)
+	atomic.StoreInt64(&s.netOutputBytes, 0)
+}
+
+// resetStats implements CONFIG RESETSTAT.
+func (s *Server) resetStats() {
+	s.stats.reset()
+	s.dbs.ResetStats()
+	for _, cmd := range commandTable {
+		cmd.stats.reset()
+		for _, sub := range cmd.subcommands {
+			sub.stats.reset()
+		}
+	}
+}
+
+// shutdownState exists while a shutdown is draining. Clients that try to
+// run a command meanwhile block until resume is closed by an abort or the
+// server is done.
+type shutdownState struct {
+	abort     chan struct{}
+	resume    chan struct{}
+	committed bool
+}
+
+// pauseState is set by CLIENT PAUSE. done is closed when the pause is
+// lifted or replaced, waking the clients blocked on it.
+type pauseState struct {
+	until time.Time
+	all   bool
+	done  chan struct{}
+}
+
+func (p *pauseState) blocks(cmd *command) bool {
+	if cmd.fullName() == "client|unpause" || !time.Now().Before(p.until) {
+		return false
+	}
+	return p.all || cmd.has(flagWrite)
+}
+
+type shutdownOptions struct {
+	save   bool
+	nosave bool
+	now    bool
+	force  bool
+}
+
+var (
+	errShutdownInProgress = errors.New("ERR Shutdown already in progress.")
+	errShutdownAborted    = errors.New("ERR Shutdown was aborted.")
+	errShutdownFailed     = errors.New("ERR Errors trying to SHUTDOWN. Check logs.")
+	errNoShutdown         = errors.New("ERR No shutdown in progress.")
+)
+
+// ErrServerClosed is returned by Serve and ListenAndServe once the server
+// has shut down.
+var ErrServerClosed = errors.New("server: closed")
+
+type options struct {
+	config           *config.Manager
+	logger           *logging.Logger
+	store            *store.KeyValueStore
+	addr             string
+	persistence      bool
+	snapshotPath     string
+	snapshotInterval time.Duration
+}
+
+type Option func(*options)
+
+// WithConfig runs the server with cfg, typically loaded from a redis.conf
+// file, instead of the defaults. CONFIG SET updates cfg in place.
+func WithConfig(cfg *config.Manager) Option {
+	return func(o *options) {
+		o.config = cfg
+	}
+}
+
+// WithLogger sends the server log to l instead of a logger built from the
+// logfile, log-format and loglevel parameters.
+func WithLogger(l *logging.Logger) Option {
+	return func(o *options) {
+		o.logger = l
+	}
+}
+
+// WithStore serves the data in st as database 0 instead of an empty store.
+func WithStore(st *store.KeyValueStore) Option {
+	return func(o *options) {
+		o.store = st
+	}
+}
+
+// WithAddr accepts plaintext connections on addr, a host:port, instead of
+// the bind and port parameters. Port 0 picks a free port; Addr reports it.
+func WithAddr(addr string) Option {
+	return func(o *options) {
+		o.addr = addr
+	}
+}
+
+// WithPersistence loads the snapshot at path on startup and saves it there
+// every interval and on shutdown. An interval of 0 only loads it.
+//
+// Without WithPersistence or WithConfig the data lives in memory only:
+// no snapshot is loaded, and none is saved unless SHUTDOWN SAVE asks.
+func WithPersistence(path string, interval time.Duration) Option {
+	return func(o *options) {
+		o.persistence = true
+		o.snapshotPath = path
+		o.snapshotInterval = interval
+	}
+}
+
+// New builds a server from opts, loading the ACL file and the snapshot
+// when they are configured.
+func New(opts ...Option) (*Server, error) {
+	o := options{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	cfg := o.config
+	if cfg == nil {
+		cfg = config.New()
+		if !o.persistence {
+			cfg.Override("snapshot-interval", "0")
+		}
+	}
+	if o.persistence {
+		for _, pair := range [][2]string{
+			{"dir", filepath.Dir(o.snapshotPath)},
+			{"dbfilename", filepath.Base(o.snapshotPath)},
+			{"snapshot-interval", strconv.FormatInt(int64(o.snapshotInterval/time.Second), 10)},
+		} {
+			if err := cfg.Override(pair[0], pair[1]); err != nil {
+				return nil, fmt.Errorf("invalid %s: %w", pair[0], err)
+			}
+		}
+	}
+
+	log := o.logger
+	if log == nil {
+		var err error
+		if log, err = logging.New(cfg.Current().LogOptions()); err != nil {
+			return nil, fmt.Errorf("opening log: %w", err)
+		}
+	}
+	dbs := make([]*store.KeyValueStore, cfg.Current().Databases)
+	for i := range dbs {
+		dbs[i] = store.NewKVStore()
+	}
+	if o.store != nil {
+		dbs[0] = o.store
+	}
+
+	s := newServer(cfg, log, store.NewDatabases(dbs...))
+	s.addr = o.addr
+	if path := cfg.Current().ACLFile; path != "" {
+		if err := s.acl.LoadFile(path); err != nil {
+			return nil, fmt.Errorf("loading ACL file: %w", err)
+		}
+	}
+	if o.config != nil || o.persistence {
+		path := cfg.Current().SnapshotPath()
+		if err := s.dbs.LoadSnapshot(path); err != nil {
+			log.Warning("Error loading snapshot", "err", err)
+		} else if info, err := os.Stat(path); err == nil {
+			s.saves.lastSave = info.ModTime()
+			log.Notice("Snapshot loaded", "path", path)
+		}
+	}
+	return s, nil
+}
+
+func newServer(cfg *config.Manager, log *logging.Logger, dbs *store.Databases) *Server {
+	cfg.OnChange(func(c config.Config) { log.SetLevel(c.LogLevel) })
+	runID := make([]byte, 20)
+	rand.Read(runID)
+	now := time.Now()
+	s := &Server{
+		config:    cfg,
+		log:       log,
+		startTime: now,
+		runID:     hex.EncodeToString(runID),
+		saves:     saveStats{lastOK: true},
+		dbs:       dbs,
+		acl:       acl.NewRegistry(func(name string) bool { return lookupCommandByName(name) != nil }),
+		tls: tlsconfig.NewReloader(func() tlsconfig.Options { return cfg.Current().TLSOptions() }, func(err error) {
+			log.Warning("Error reloading TLS certificates, keeping the previous ones", "err", err)
+		}),
+		ready:   make(chan struct{}),
+		clients: make(map[*client]struct{}),
+		done:    make(chan struct{}),
+	}
+	s.dbs.SetLatencyHook(s.recordLatency)
+	cfg.OnChange(func(c config.Config) { s.slow.SetMaxLen(c.SlowlogMaxLen) })
+	return s
+}
+
+// recordLatency adds a spike of event to the latency monitor when d
+// reaches latency-monitor-threshold.
+func (s *Server) recordLatency(event string, d time.Duration) {
+	threshold := s.config.Current().LatencyMonitorThreshold
+	if threshold > 0 && d >= time.Duration(threshold)*time.Millisecond {
+		s.latency.Add(event, d)
+	}
+}
+
+// ListenAndServe serves clients until the server shuts down, like Serve
+// with a context that is never done.
+func (s *Server) ListenAndServe() error {
+	return s.Serve(context.Background())
+}
+
+// Serve opens the configured listeners and serves clients, running the
+// periodic snapshots and the deletion of expired keys, until the server
+// shuts down through Shutdown or the SHUTDOWN command, or ctx is done.
+// When ctx is done the server shuts down like Shutdown, except that it
+// stops even if the final snapshot cannot be saved. Serve returns
+// ErrServerClosed once the server has stopped.
+func (s *Server) Serve(ctx context.Context) error {
+	s.mu.Lock()
+	serving := s.serving
+	s.serving = true
+	s.mu.Unlock()
+	if serving {
+		return errors.New("server: Serve called twice")
+	}
+	if s.stopped() {
+		return ErrServerClosed
+	}
+
+	err := s.listen()
+	s.readyOnce.Do(func() { close(s.ready) })
+	if err != nil {
+		return err
+	}
+	go s.periodicSnapshot()
+	go s.activeExpireCycle()
+
+	select {
+	case <-s.done:
+	case <-ctx.Done():
+		s.log.Notice("Context done, shutting down")
+		for !s.stopped() {
+			if err := s.stop(context.Background(), shutdownOptions{force: true}); err != nil {
+				// Another shutdown is in progress, or was aborted; try
+				// again until the server has stopped.
+				time.Sleep(10 * time.Millisecond)
+			}
+		}
+	}
+	s.log.Notice("Server stopped")
+	return ErrServerClosed
+}
+
+// Shutdown stops the server gracefully, like the SHUTDOWN command: it
+// stops accepting connections, waits for in-flight commands until
+// shutdown-timeout passes or ctx is done, saves the final snapshot when
+// persistence is enabled and closes every client. If the snapshot cannot
+// be saved the server keeps running and Shutdown returns an error.
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.stop(ctx, shutdownOptions{})
+}
+
+// Addr returns the address of the plaintext listener, or of the TLS or
+// Unix socket listener when there is none. It waits until Serve has
+// opened its listeners, and returns nil if that failed or the server has
+// shut down.
+func (s *Server) Addr() net.Addr {
+	<-s.ready
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.listeners) == 0 {
+		return nil
+	}
+	return s.listeners[0].Addr()
+}
+
+func (s *Server) stopped() bool {
+	select {
+	case <-s.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// listen opens the plaintext and TLS ports and the Unix socket that are
+// configured; setting port to 0 disables plaintext TCP.
+func (s *Server) listen() error {
+	cfg := s.config.Current()
+	listeners := []net.Listener{}
+	fail := func(err error) error {
+		for _, listener := range listeners {
+			listener.Close()
+		}
+		return err
+	}
+
+	s.mu.Lock()
+	addr := s.addr
+	s.mu.Unlock()
+	if addr != "" || cfg.Port != 0 {
+		address := net.JoinHostPort(cfg.Bind, strconv.Itoa(cfg.Port))
+		if addr != "" {
+			address = addr
+		}
+		listener, err := net.Listen("tcp", address)
+		if err != nil {
+			return fail(err)
+		}
+		listeners = append(listeners, listener)
+		if addr != "" {
+			address = listener.Addr().String()
+			s.useAddr(listener.Addr().(*net.TCPAddr))
+		}
+		s.log.Notice("Ready to accept connections", "transport", "tcp", "addr", address)
+	}
+
+	if cfg.TLSPort != 0 {
+		if _, err := s.tls.Config(); err != nil {
+			return fail(fmt.Errorf("configuring TLS: %w", err))
+		}
+		address := net.JoinHostPort(cfg.Bind, strconv.Itoa(cfg.TLSPort))
+		listener, err := net.Listen("tcp", address)
+		if err != nil {
+			return fail(err)
+		}
+		listeners = append(listeners, tls.NewListener(listener, s.tls.ServerConfig()))
+		s.log.Notice("Ready to accept connections", "transport", "tls", "addr", address)
+	}
+
+	if cfg.UnixSocket != "" {
+		// A socket file left behind by a crashed instance would make the
+		// bind fail.
+		os.Remove(cfg.UnixSocket)
+		listener, err := net.Listen("unix", cfg.UnixSocket)
+		if err != nil {
+			return fail(err)
+		}
+		listeners = append(listeners, listener)
+		if cfg.UnixSocketPerm != 0 {
+			if err := os.Chmod(cfg.UnixSocket, os.FileMode(cfg.UnixSocketPerm)); err != nil {
+				return fail(err)
+			}
+		}
+		s.log.Notice("Ready to accept connections", "transport", "unix", "path", cfg.UnixSocket)
+	}
+
+	if len(listeners) == 0 {
+		return fmt.Errorf("nothing to listen on, set port, tls-port or unixsocket")
+	}
+
+	var metricsListener net.Listener
+	if cfg.MetricsPort != 0 {
+		address := net.JoinHostPort(cfg.Bind, strconv.Itoa(cfg.MetricsPort))
+		listener, err := net.Listen("tcp", address)
+		if err != nil {
+			return fail(err)
+		}
+		metricsListener = listener
+		s.log.Notice("Serving Prometheus metrics", "addr", address, "path", "/metrics")
+	}
+
+	s.mu.Lock()
+	s.listeners = listeners
+	if metricsListener != nil {
+		s.listeners = append(s.listeners, metricsListener)
+	}
+	s.mu.Unlock()
+
+	for _, listener := range listeners {
+		go s.acceptLoop(listener)
+	}
+	if metricsListener != nil {
+		go s.serveMetrics(metricsListener)
+	}
+	return nil
+}
+
+// useAddr records the address a WithAddr listener is bound to in bind and
+// port, so INFO and CONFIG GET report it and a listener reopened after an
+// aborted shutdown binds the same port.
+func (s *Server) useAddr(addr *net.TCPAddr) {
+	s.config.Override("bind", addr.IP.String())
+	s.config.Override("port", strconv.Itoa(addr.Port))
+	s.mu.Lock()
+	s.addr = ""
+	s.mu.Unlock()
+}
+
+func (s *Server) acceptLoop(listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			s.log.Warning("Error accepting connection", "err", err)
+			continue
+		}
+		go s.handleConnection(conn)
+	}
+}
+
+func (s *Server) addClient(c *client) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	select {
+	case <-s.done:
+		return false
+	default:
+	}
+	s.clients[c] = struct{}{}
+	return true
+}
+
+func (s *Server) removeClient(c *client) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.clients, c)
+}
+
+// beginCommand registers a command as in flight. While a shutdown is
+// draining, or CLIENT PAUSE applies to the command, it blocks instead, and
+// returns false if the server stopped in the meantime.
+func (s *Server) beginCommand(c *client, cmd *command) bool {
+	for {
+		atomic.AddInt64(&s.active, 1)
+		s.mu.Lock()
+		shutdown, pause := s.shutdown, s.pause
+		s.mu.Unlock()
+		if pause != nil && !pause.blocks(cmd) {
+			pause = nil
+		}
+		if shutdown == nil && pause == nil {
+			return true
+		}
+
+		atomic.AddInt64(&s.active, -1)
+		c.w.Flush()
+		if shutdown != nil {
+			select {
+			case <-shutdown.resume:
+			case <-s.done:
+				return false
+			}
+			continue
+		}
+
+		timer := time.NewTimer(time.Until(pause.until))
+		select {
+		case <-pause.done:
+		case <-timer.C:
+			s.unpause(pause)
+		case <-s.done:
+			timer.Stop()
+			return false
+		}
+		timer.Stop()
+	}
+}
+
+func (s *Server) pauseClients(timeout time.Duration, all bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pause := &pauseState{until: time.Now().Add(timeout), all: all, done: make(chan struct{})}
+	if old := s.pause; old != nil {
+		if old.until.After(pause.until) {
+			pause.until = old.until
+		}
+		pause.all = pause.all || old.all
+		close(old.done)
+	}
+	s.pause = pause
+}
+
+// unpause lifts the given pause, or the current one when pause is nil.
+func (s *Server) unpause(pause *pauseState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pause == nil || (pause != nil && s.pause != pause) {
+		return
+	}
+	close(s.pause.done)
+	s.pause = nil
+}
+
+func (s *Server) clientList() []*client {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	clients := make([]*client, 0, len(s.clients))
+	for c := range s.clients {
+		clients = append(clients, c)
+	}
+	sort.Slice(clients, func(i, j int) bool { return clients[i].id < clients[j].id })
+	return clients
+}
+
+func (s *Server) endCommand() {
+	atomic.AddInt64(&s.active, -1)
+}
+
+// stop shuts the server down: it stops accepting connections, waits for
+// in-flight commands to finish, saves a final snapshot and closes every
+// client. SHUTDOWN itself is never counted as in flight, so it does not
+// wait for itself.
+func (s *Server) stop(ctx context.Context, opts shutdownOptions) error {
+	s.mu.Lock()
+	if s.shutdown != nil {
+		s.mu.Unlock()
+		return errShutdownInProgress
+	}
+	state := &shutdownState{abort: make(chan struct{}), resume: make(chan struct{})}
+	s.shutdown = state
+	listeners := s.listeners
+	s.listeners = nil
+	s.mu.Unlock()
+
+	for _, listener := range listeners {
+		listener.Close()
+	}
+
+	if !opts.now {
+		if err := s.drain(ctx, state); err != nil {
+			s.resumeAfterShutdown(state)
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	state.committed = true
+	s.mu.Unlock()
+
+	cfg := s.config.Current()
+	if opts.save || (!opts.nosave && cfg.SnapshotInterval > 0) {
+		s.log.Notice("Saving the final snapshot before exiting", "path", cfg.SnapshotPath())
+		if err := s.saveSnapshot(cfg.SnapshotPath()); err != nil {
+			s.log.Warning("Error saving snapshot during shutdown", "err", err)
+			if !opts.force {
+				s.resumeAfterShutdown(state)
+				return errShutdownFailed
+			}
+		} else {
+			s.log.Notice("Snapshot saved")
+		}
+	}
+
+	s.mu.Lock()
+	close(s.done)
+	clients := make([]*client, 0, len(s.clients))
+	for c := range s.clients {
+		clients = append(clients, c)
+	}
+	s.mu.Unlock()
+	s.readyOnce.Do(func() { close(s.ready) })
+
+	for _, c := range clients {
+		c.conn.Close()
+	}
+	return nil
+}
+
+func (s *Server) drain(ctx context.Context, state *shutdownState) error {
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	deadline := time.Now().Add(s.config.Current().ShutdownTimeout)
+	for atomic.LoadInt64(&s.active) > 0 {
+		if time.Now().After(deadline) {
+			s.log.Warning("Timed out waiting for in-flight commands, shutting down anyway")
+			return nil
+		}
+		select {
+		case <-state.abort:
+			return errShutdownAborted
+		case <-ctx.Done():
+			s.log.Warning("Stopped waiting for in-flight commands, shutting down anyway", "err", ctx.Err())
+			return nil
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+func (s *Server) resumeAfterShutdown(state *shutdownState) {
+	s.mu.Lock()
+	serving := s.serving
+	s.mu.Unlock()
+	if serving {
+		if err := s.listen(); err != nil {
+			s.log.Warning("Error reopening listener after aborted shutdown", "err", err)
+		}
+	}
+	s.mu.Lock()
+	s.shutdown = nil
+	s.mu.Unlock()
+	close(state.resume)
+}
+
+func (s *Server) abortShutdown() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.shutdown == nil || s.shutdown.committed {
+		return errNoShutdown
+	}
+	select {
+	case <-s.shutdown.abort:
+	default:
+		close(s.shutdown.abort)
+	}
+	return nil
+}
+
+// unixTime returns t in Unix seconds, or 0 for the zero time.
+func unixTime(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+// saveSnapshot writes the dataset to path and records the outcome for
+// INFO persistence.
+func (s *Server) saveSnapshot(path string) error {
+	s.saves.mu.Lock()
+	s.saves.inProgress = true
+	s.saves.mu.Unlock()
+
+	dirty := atomic.LoadInt64(&s.dirty)
+	start := time.Now()
+	err := s.dbs.SaveSnapshot(path)
+
+	s.saves.mu.Lock()
+	defer s.saves.mu.Unlock()
+	s.saves.inProgress = false
+	s.saves.lastOK = err == nil
+	s.saves.lastDuration = time.Since(start)
+	s.recordLatency("snapshot-save", s.saves.lastDuration)
+	if err == nil {
+		s.saves.lastSave = start
+		atomic.AddInt64(&s.dirty, -dirty)
+	}
+	return err
+}
+
+// periodicSnapshot checks once a second whether snapshot-interval has
+// elapsed, so CONFIG SET takes effect without restarting the loop.
+func (s *Server) periodicSnapshot() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	lastSave := time.Now()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+		}
+		cfg := s.config.Current()
+		if cfg.SnapshotInterval <= 0 || time.Since(lastSave) < cfg.SnapshotInterval {
+			continue
+		}
+		lastSave = time.Now()
+		if err := s.saveSnapshot(cfg.SnapshotPath()); err != nil {
+			s.log.Warning("Error saving snapshot", "err", err)
+		} else {
+			s.log.Verbose("Snapshot saved", "path", cfg.SnapshotPath())
+		}
+	}
+}
+
+// activeExpireCycle deletes expired keys ten times a second, so keys that
+// are never read again do not linger until the next restart.
+func (s *Server) activeExpireCycle() {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+		}
+		start := time.Now()
+		s.dbs.CleanupExpiredKeys()
+		s.recordLatency("expire-cycle", time.Since(start))
+	}
+}
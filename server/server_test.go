@@ -0,0 +1,147 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"mini-redis/logging"
+	"mini-redis/protocol"
+	"mini-redis/store"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func quietLogger(t *testing.T) *logging.Logger {
+	l, err := logging.New(logging.Options{File: filepath.Join(t.TempDir(), "server.log"), Format: "text", Level: "warning"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+type testConn struct {
+	t      *testing.T
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func dial(t *testing.T, addr net.Addr) *testConn {
+	conn, err := net.Dial(addr.Network(), addr.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return &testConn{t: t, conn: conn, reader: bufio.NewReader(conn)}
+}
+
+func (c *testConn) do(args ...string) protocol.Value {
+	c.t.Helper()
+	c.send(args...)
+	return c.read()
+}
+
+// send writes a command without waiting for its reply.
+func (c *testConn) send(args ...string) {
+	c.t.Helper()
+	if _, err := c.conn.Write(protocol.Encode(protocol.NewStringArray(args), protocol.RESP2)); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+func (c *testConn) read() protocol.Value {
+	c.t.Helper()
+	reply, err := protocol.Decode(c.reader)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	return reply
+}
+
+func serve(ctx context.Context, srv *Server) <-chan error {
+	errc := make(chan error, 1)
+	go func() { errc <- srv.Serve(ctx) }()
+	return errc
+}
+
+// startServer starts a server on a free port, shut down when the test ends.
+func startServer(t *testing.T) *Server {
+	t.Helper()
+	srv, err := New(WithAddr("127.0.0.1:0"), WithLogger(quietLogger(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	serve(context.Background(), srv)
+	t.Cleanup(func() { srv.Shutdown(context.Background()) })
+	return srv
+}
+
+func TestServeShutdownAndReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dump.json")
+	srv, err := New(WithAddr("127.0.0.1:0"), WithPersistence(path, time.Hour), WithLogger(quietLogger(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	errc := serve(context.Background(), srv)
+
+	addr := srv.Addr()
+	if addr == nil || addr.(*net.TCPAddr).Port == 0 {
+		t.Fatalf("Addr() = %v", addr)
+	}
+	c := dial(t, addr)
+	if reply := c.do("SET", "greeting", "hello"); reply.Str != "OK" {
+		t.Fatalf("SET replied %+v", reply)
+	}
+	if reply := c.do("CONFIG", "GET", "port"); len(reply.Elems) != 2 || reply.Elems[1].Str == "0" {
+		t.Errorf("CONFIG GET port = %+v", reply)
+	}
+
+	if err := srv.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if err := <-errc; !errors.Is(err, ErrServerClosed) {
+		t.Fatalf("Serve returned %v", err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("no snapshot saved on shutdown: %v", err)
+	}
+	if srv.Addr() != nil {
+		t.Error("Addr() is not nil after Shutdown")
+	}
+
+	st := store.NewKVStore()
+	if _, err := New(WithStore(st), WithPersistence(path, 0), WithLogger(quietLogger(t))); err != nil {
+		t.Fatal(err)
+	}
+	if value, ok, _ := st.Get("greeting"); !ok || value != "hello" {
+		t.Errorf("reloaded greeting = %q, %v", value, ok)
+	}
+}
+
+func TestServeStopsWhenContextIsDone(t *testing.T) {
+	srv, err := New(WithAddr("127.0.0.1:0"), WithLogger(quietLogger(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := serve(ctx, srv)
+	c := dial(t, srv.Addr())
+	if reply := c.do("GET", "missing"); !reply.IsNull {
+		t.Fatalf("GET replied %+v", reply)
+	}
+
+	cancel()
+	select {
+	case err := <-errc:
+		if !errors.Is(err, ErrServerClosed) {
+			t.Fatalf("Serve returned %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve did not return after the context was cancelled")
+	}
+	if _, err := c.reader.ReadByte(); err == nil {
+		t.Error("connection still open after shutdown")
+	}
+}
@@ -0,0 +1,121 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// registerBlockingCommand adds a command that stays in flight until release
+// is closed, and signals started when it begins.
+func registerBlockingCommand(t *testing.T) (started, release chan struct{}) {
+	started, release = make(chan struct{}, 1), make(chan struct{})
+	registerCommand(&command{name: "test-block", arity: 1, group: "server", handler: func(c *client, args []string) {
+		started <- struct{}{}
+		<-release
+		c.w.WriteSimpleString("OK")
+	}})
+	t.Cleanup(func() { delete(commandTable, "test-block") })
+	return started, release
+}
+
+func TestShutdownWaitsForInFlightCommands(t *testing.T) {
+	started, release := registerBlockingCommand(t)
+	srv, err := New(WithAddr("127.0.0.1:0"), WithLogger(quietLogger(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	errc := serve(context.Background(), srv)
+	busy, admin := dial(t, srv.Addr()), dial(t, srv.Addr())
+
+	busy.send("TEST-BLOCK")
+	<-started
+	admin.send("SHUTDOWN", "NOSAVE")
+	select {
+	case err := <-errc:
+		t.Fatalf("Serve returned %v with a command in flight", err)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	close(release)
+	if reply := busy.read(); reply.Str != "OK" {
+		t.Errorf("in-flight command replied %+v", reply)
+	}
+	select {
+	case err := <-errc:
+		if !errors.Is(err, ErrServerClosed) {
+			t.Fatalf("Serve returned %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("SHUTDOWN did not finish after the command did")
+	}
+}
+
+func TestShutdownAbort(t *testing.T) {
+	started, release := registerBlockingCommand(t)
+	srv, err := New(WithAddr("127.0.0.1:0"), WithLogger(quietLogger(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	serve(context.Background(), srv)
+	t.Cleanup(func() { srv.Shutdown(context.Background()) })
+	busy, shutdown, admin := dial(t, srv.Addr()), dial(t, srv.Addr()), dial(t, srv.Addr())
+
+	if reply := admin.do("SHUTDOWN", "ABORT"); reply.Str != errNoShutdown.Error() {
+		t.Errorf("SHUTDOWN ABORT without a shutdown replied %+v", reply)
+	}
+	busy.send("TEST-BLOCK")
+	<-started
+	shutdown.send("SHUTDOWN")
+	// Give SHUTDOWN time to start draining.
+	time.Sleep(50 * time.Millisecond)
+	if reply := admin.do("SHUTDOWN", "ABORT"); reply.Str != "OK" {
+		t.Fatalf("SHUTDOWN ABORT replied %+v", reply)
+	}
+	if reply := shutdown.read(); reply.Str != errShutdownAborted.Error() {
+		t.Errorf("aborted SHUTDOWN replied %+v", reply)
+	}
+
+	close(release)
+	if reply := busy.read(); reply.Str != "OK" {
+		t.Errorf("in-flight command replied %+v", reply)
+	}
+	if reply := admin.do("GET", "missing"); !reply.IsNull {
+		t.Errorf("GET after ABORT replied %+v", reply)
+	}
+	if addr := srv.Addr(); addr == nil {
+		t.Error("the server stopped listening after ABORT")
+	} else {
+		dial(t, addr).do("GET", "missing")
+	}
+}
+
+func TestShutdownSaveAndNoSave(t *testing.T) {
+	for _, tc := range []struct {
+		arg      string
+		interval time.Duration
+		saved    bool
+	}{
+		{"NOSAVE", time.Hour, false},
+		{"SAVE", 0, true},
+	} {
+		path := filepath.Join(t.TempDir(), "dump.json")
+		srv, err := New(WithAddr("127.0.0.1:0"), WithPersistence(path, tc.interval), WithLogger(quietLogger(t)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		errc := serve(context.Background(), srv)
+		c := dial(t, srv.Addr())
+		c.do("SET", "k", "v")
+		c.send("SHUTDOWN", tc.arg)
+		if err := <-errc; !errors.Is(err, ErrServerClosed) {
+			t.Fatalf("SHUTDOWN %s: Serve returned %v", tc.arg, err)
+		}
+		if _, err := os.Stat(path); (err == nil) != tc.saved {
+			t.Errorf("SHUTDOWN %s: snapshot saved = %v, want %v", tc.arg, err == nil, tc.saved)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package server
+
+import "testing"
+
+func TestSlowlogMaxLenTrimsOnConfigSet(t *testing.T) {
+	srv := startServer(t)
+	c := dial(t, srv.Addr())
+
+	c.do("CONFIG", "SET", "slowlog-log-slower-than", "0")
+	for _, key := range []string{"a", "b", "c"} {
+		c.do("GET", key)
+	}
+	// Disabling the log in the same call means no Add can do the trimming.
+	c.do("CONFIG", "SET", "slowlog-max-len", "2", "slowlog-log-slower-than", "-1")
+	if reply := c.do("SLOWLOG", "LEN"); reply.Int != 2 {
+		t.Errorf("SLOWLOG LEN after lowering slowlog-max-len = %+v, want 2", reply)
+	}
+	entries := c.do("SLOWLOG", "GET", "-1").Elems
+	if len(entries) != 2 || entries[0].Elems[3].Elems[1].Str != "c" || entries[1].Elems[3].Elems[1].Str != "b" {
+		t.Errorf("SLOWLOG GET after lowering slowlog-max-len = %+v", entries)
+	}
+}
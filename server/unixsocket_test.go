@@ -0,0 +1,67 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"mini-redis/config"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUnixSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "redis.sock")
+	// Leave a socket file behind, as a crashed instance would.
+	stale, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.SetUnlinkOnClose(false)
+	stale.Close()
+
+	cfg := config.New()
+	settings := map[string]string{"port": "0", "unixsocket": path, "unixsocketperm": "700", "dir": t.TempDir()}
+	for name, value := range settings {
+		if err := cfg.Override(name, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	srv, err := New(WithConfig(cfg), WithLogger(quietLogger(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	errc := serve(context.Background(), srv)
+
+	addr := srv.Addr()
+	if addr == nil || addr.Network() != "unix" {
+		t.Fatalf("Addr() = %v, want the Unix socket", addr)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode()&os.ModeSocket == 0 || info.Mode().Perm() != 0o700 {
+		t.Errorf("socket mode = %v, want a socket with permissions 0700", info.Mode())
+	}
+
+	c := dial(t, addr)
+	c.do("SET", "k", "v")
+	if reply := c.do("GET", "k"); reply.Str != "v" {
+		t.Errorf("GET over the Unix socket replied %+v", reply)
+	}
+	if reply := c.do("CLIENT", "INFO"); !strings.Contains(reply.Str, "addr="+path+":0 ") || !strings.Contains(reply.Str, "flags=U ") {
+		t.Errorf("CLIENT INFO over the Unix socket = %q", reply.Str)
+	}
+
+	if err := srv.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errc; !errors.Is(err, ErrServerClosed) {
+		t.Fatalf("Serve returned %v", err)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("socket file left behind after shutdown: %v", err)
+	}
+}
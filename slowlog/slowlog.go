@@ -0,0 +1,138 @@
+package slowlog
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Like Redis, entries keep at most maxArgs arguments of at most
+// maxArgLen bytes each, so a huge command cannot blow up the log.
+const (
+	maxArgs   = 32
+	maxArgLen = 128
+)
+
+type Entry struct {
+	ID         int64
+	Time       time.Time
+	Duration   time.Duration
+	Args       []string
+	ClientAddr string
+	ClientName string
+}
+
+// Log is a bounded ring buffer of the most recent slow commands. The
+// buffer grows as entries arrive, so a large maxLen costs nothing until
+// it fills up.
+type Log struct {
+	mu     sync.Mutex
+	buf    []Entry
+	maxLen int
+	// head is the oldest entry once buf is full, and 0 until then.
+	head   int
+	nextID int64
+}
+
+// Add records entry, assigning its ID and truncating its arguments. The
+// log keeps the newest maxLen entries.
+func (l *Log) Add(entry Entry, maxLen int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry.ID = l.nextID
+	l.nextID++
+	entry.Args = truncate(entry.Args)
+
+	l.setMaxLen(maxLen)
+	switch {
+	case maxLen <= 0:
+	case len(l.buf) < maxLen:
+		l.buf = append(l.buf, entry)
+	default:
+		l.buf[l.head] = entry
+		l.head = (l.head + 1) % len(l.buf)
+	}
+}
+
+// SetMaxLen trims the log to its newest maxLen entries right away, rather
+// than on the next Add.
+func (l *Log) SetMaxLen(maxLen int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.setMaxLen(maxLen)
+}
+
+// setMaxLen lays the newest maxLen entries out oldest first, so appending
+// can resume. It allocates only for the entries kept.
+func (l *Log) setMaxLen(maxLen int) {
+	if maxLen == l.maxLen {
+		return
+	}
+	l.maxLen = maxLen
+	if maxLen <= 0 {
+		l.buf, l.head = nil, 0
+		return
+	}
+	if l.head == 0 && len(l.buf) <= maxLen {
+		return
+	}
+	kept := l.newest(maxLen)
+	l.buf = make([]Entry, len(kept))
+	// Entries come back newest first.
+	for i := range kept {
+		l.buf[i] = kept[len(kept)-1-i]
+	}
+	l.head = 0
+}
+
+func (l *Log) newest(count int) []Entry {
+	if count < 0 || count > len(l.buf) {
+		count = len(l.buf)
+	}
+	entries := make([]Entry, 0, count)
+	for i := 1; i <= count; i++ {
+		entries = append(entries, l.buf[(l.head-i+len(l.buf))%len(l.buf)])
+	}
+	return entries
+}
+
+// Entries returns up to count entries, newest first. A negative count
+// returns all of them.
+func (l *Log) Entries(count int) []Entry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.newest(count)
+}
+
+func (l *Log) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.buf)
+}
+
+func (l *Log) Reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.buf, l.head = nil, 0
+}
+
+func truncate(args []string) []string {
+	count := len(args)
+	if count > maxArgs {
+		count = maxArgs
+	}
+	result := make([]string, count)
+	for i := 0; i < count; i++ {
+		if i == maxArgs-1 && len(args) > maxArgs {
+			result[i] = fmt.Sprintf("... (%d more arguments)", len(args)-maxArgs+1)
+			break
+		}
+		arg := args[i]
+		if len(arg) > maxArgLen {
+			arg = fmt.Sprintf("%s... (%d more bytes)", arg[:maxArgLen], len(arg)-maxArgLen)
+		}
+		result[i] = arg
+	}
+	return result
+}
@@ -0,0 +1,98 @@
+package slowlog
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRingKeepsNewest(t *testing.T) {
+	var l Log
+	for i := 0; i < 5; i++ {
+		l.Add(Entry{Args: []string{"cmd", string(rune('a' + i))}, Duration: time.Millisecond}, 3)
+	}
+	if l.Len() != 3 {
+		t.Fatalf("Len = %d, want 3", l.Len())
+	}
+	entries := l.Entries(-1)
+	for i, want := range []int64{4, 3, 2} {
+		if entries[i].ID != want {
+			t.Errorf("entry %d has ID %d, want %d", i, entries[i].ID, want)
+		}
+	}
+	if got := l.Entries(1); len(got) != 1 || got[0].Args[1] != "e" {
+		t.Errorf("Entries(1) = %+v", got)
+	}
+
+	// Shrinking keeps the newest entries, growing keeps them all.
+	l.Add(Entry{Args: []string{"cmd", "f"}}, 2)
+	if entries := l.Entries(-1); len(entries) != 2 || entries[0].Args[1] != "f" || entries[1].Args[1] != "e" {
+		t.Errorf("after shrinking: %+v", entries)
+	}
+	l.Add(Entry{Args: []string{"cmd", "g"}}, 10)
+	if l.Len() != 3 {
+		t.Errorf("after growing: Len = %d, want 3", l.Len())
+	}
+
+	l.SetMaxLen(1)
+	if entries := l.Entries(-1); len(entries) != 1 || entries[0].Args[1] != "g" {
+		t.Errorf("after SetMaxLen(1): %+v", entries)
+	}
+
+	l.Reset()
+	if l.Len() != 0 || len(l.Entries(-1)) != 0 {
+		t.Error("Reset left entries behind")
+	}
+	l.Add(Entry{Args: []string{"cmd"}}, 10)
+	if got := l.Entries(-1); got[0].ID != 7 {
+		t.Errorf("IDs restarted after Reset: %d", got[0].ID)
+	}
+}
+
+func TestLargeMaxLenAllocatesLazily(t *testing.T) {
+	var l Log
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	l.SetMaxLen(1<<31 - 1)
+	for i := 0; i < 3; i++ {
+		l.Add(Entry{Args: []string{"cmd"}}, 1<<31-1)
+	}
+	runtime.ReadMemStats(&after)
+	if grown := after.TotalAlloc - before.TotalAlloc; grown > 1<<20 {
+		t.Errorf("a huge maxLen allocated %d bytes for 3 entries", grown)
+	}
+
+	// Growing a wrapped buffer keeps the entries in order.
+	l.SetMaxLen(2)
+	l.Add(Entry{Args: []string{"cmd"}}, 2)
+	l.SetMaxLen(4)
+	l.Add(Entry{Args: []string{"cmd"}}, 4)
+	l.Add(Entry{Args: []string{"cmd"}}, 4)
+	var ids []int64
+	for _, entry := range l.Entries(-1) {
+		ids = append(ids, entry.ID)
+	}
+	if len(ids) != 4 || ids[0] != 5 || ids[1] != 4 || ids[2] != 3 || ids[3] != 2 {
+		t.Errorf("IDs after growing = %v, want [5 4 3 2]", ids)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	args := make([]string, 40)
+	for i := range args {
+		args[i] = "x"
+	}
+	args[0] = strings.Repeat("a", 200)
+
+	got := truncate(args)
+	if len(got) != maxArgs {
+		t.Fatalf("got %d args, want %d", len(got), maxArgs)
+	}
+	if got[0] != strings.Repeat("a", 128)+"... (72 more bytes)" {
+		t.Errorf("long argument: %q", got[0])
+	}
+	if got[maxArgs-1] != "... (9 more arguments)" {
+		t.Errorf("last argument: %q", got[maxArgs-1])
+	}
+}
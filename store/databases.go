@@ -0,0 +1,248 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Snapshots of several databases have version 3; older versions hold a
+// single database and are loaded into database 0.
+const databasesSnapshotVersion = 3
+
+type databasesSnapshot struct {
+	Version   int          `json:"version"`
+	Databases []dbSnapshot `json:"databases"`
+}
+
+type dbSnapshot struct {
+	DB int `json:"db"`
+	snapshot
+}
+
+// Databases are the numbered keyspaces a server offers, each with its own
+// keys and expiry state. Operations that lock two databases lock the one
+// with the lower index first, so they cannot deadlock each other.
+type Databases struct {
+	dbs []*KeyValueStore
+}
+
+// NewDatabases numbers dbs from 0.
+func NewDatabases(dbs ...*KeyValueStore) *Databases {
+	return &Databases{dbs: dbs}
+}
+
+func (d *Databases) Len() int {
+	return len(d.dbs)
+}
+
+// DB returns database index. The same store serves the index for the
+// lifetime of d, even across Swap, so callers may keep it.
+func (d *Databases) DB(index int) *KeyValueStore {
+	return d.dbs[index]
+}
+
+func (d *Databases) lockPair(i, j int) (first, second *KeyValueStore) {
+	if i > j {
+		i, j = j, i
+	}
+	d.dbs[i].lock()
+	d.dbs[j].lock()
+	return d.dbs[i], d.dbs[j]
+}
+
+func (d *Databases) unlockPair(first, second *KeyValueStore) {
+	second.mutex.Unlock()
+	first.mutex.Unlock()
+}
+
+// Move moves key, with its TTL, from database from to database to. It
+// returns false if key does not exist in from or already exists in to.
+func (d *Databases) Move(key string, from, to int) bool {
+	if from == to {
+		return false
+	}
+	first, second := d.lockPair(from, to)
+	defer d.unlockPair(first, second)
+
+	src, dst := d.dbs[from], d.dbs[to]
+	obj, _ := src.lookupWrite(key, 0)
+	if obj == nil || dst.exists(key) {
+		return false
+	}
+	expiry, expiring := src.expires[key]
+	src.remove(key)
+	dst.add(key, obj)
+	if expiring {
+		dst.setExpiry(key, expiry)
+	}
+	return true
+}
+
+// Copy copies the value and TTL of key src in database from to key dst in
+// database to. It returns false if src does not exist, or if dst exists
+// and replace is not set.
+func (d *Databases) Copy(src string, from int, dst string, to int, replace bool) bool {
+	var source, target *KeyValueStore
+	if from == to {
+		source, target = d.dbs[from], d.dbs[from]
+		source.lock()
+		defer source.mutex.Unlock()
+	} else {
+		first, second := d.lockPair(from, to)
+		defer d.unlockPair(first, second)
+		source, target = d.dbs[from], d.dbs[to]
+	}
+
+	obj, _ := source.lookupWrite(src, 0)
+	if obj == nil || (from == to && src == dst) {
+		return false
+	}
+	if target.exists(dst) {
+		if !replace {
+			return false
+		}
+		target.remove(dst)
+	}
+	target.add(dst, obj.clone())
+	if expiry, ok := source.expires[src]; ok {
+		target.setExpiry(dst, expiry)
+	}
+	return true
+}
+
+// Swap exchanges the contents of databases i and j, so that clients using
+// one see the data of the other.
+func (d *Databases) Swap(i, j int) {
+	if i == j {
+		return
+	}
+	first, second := d.lockPair(i, j)
+	defer d.unlockPair(first, second)
+
+	a, b := d.dbs[i], d.dbs[j]
+	a.keys, b.keys = b.keys, a.keys
+	a.counts, b.counts = b.counts, a.counts
+	a.scanKeys, b.scanKeys = b.scanKeys, a.scanKeys
+	a.expires, b.expires = b.expires, a.expires
+	a.pq, b.pq = b.pq, a.pq
+}
+
+func (d *Databases) FlushAll() {
+	for _, db := range d.dbs {
+		db.Flush()
+	}
+}
+
+// SetLatencyHook sets the latency hook of every database.
+func (d *Databases) SetLatencyHook(hook func(event string, d time.Duration)) {
+	for _, db := range d.dbs {
+		db.SetLatencyHook(hook)
+	}
+}
+
+func (d *Databases) CleanupExpiredKeys() {
+	for _, db := range d.dbs {
+		db.CleanupExpiredKeys()
+	}
+}
+
+// Stats adds up the keyspace counters of every database.
+func (d *Databases) Stats() Stats {
+	total := Stats{}
+	for _, db := range d.dbs {
+		stats := db.Stats()
+		total.KeyspaceHits += stats.KeyspaceHits
+		total.KeyspaceMisses += stats.KeyspaceMisses
+		total.ExpiredKeys += stats.ExpiredKeys
+	}
+	return total
+}
+
+func (d *Databases) ResetStats() {
+	for _, db := range d.dbs {
+		db.ResetStats()
+	}
+}
+
+// KeyCounts adds up the keys of each type in every database.
+func (d *Databases) KeyCounts() KeyCounts {
+	total := KeyCounts{}
+	for _, db := range d.dbs {
+		counts := db.KeyCounts()
+		total.Strings += counts.Strings
+		total.Lists += counts.Lists
+		total.Hashes += counts.Hashes
+		total.Sets += counts.Sets
+	}
+	return total
+}
+
+// SaveSnapshot writes every non-empty database to fileName. All databases
+// are read-locked together, so the snapshot is consistent across them.
+func (d *Databases) SaveSnapshot(fileName string) error {
+	for _, db := range d.dbs {
+		db.rlock()
+	}
+	locked := time.Now()
+	data := databasesSnapshot{Version: databasesSnapshotVersion}
+	for i, db := range d.dbs {
+		dump := db.dump()
+		if len(dump.Strings)+len(dump.Lists)+len(dump.Hashes)+len(dump.Sets) > 0 {
+			data.Databases = append(data.Databases, dbSnapshot{DB: i, snapshot: dump})
+		}
+	}
+	for _, db := range d.dbs {
+		db.mutex.RUnlock()
+	}
+	d.dbs[0].reportLatency("snapshot-lock", time.Since(locked))
+
+	dataBytes, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return writeFile(fileName, dataBytes)
+}
+
+// LoadSnapshot loads fileName, which may also be a snapshot of a single
+// database. A missing file is not an error.
+func (d *Databases) LoadSnapshot(fileName string) error {
+	file, err := os.ReadFile(fileName)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	var header struct {
+		Version int `json:"version"`
+	}
+	if err := json.Unmarshal(file, &header); err != nil {
+		return err
+	}
+	if header.Version < databasesSnapshotVersion {
+		db := d.dbs[0]
+		db.lock()
+		defer db.mutex.Unlock()
+		return db.load(file)
+	}
+
+	data := databasesSnapshot{}
+	if err := json.Unmarshal(file, &data); err != nil {
+		return err
+	}
+	for _, entry := range data.Databases {
+		if entry.DB < 0 || entry.DB >= len(d.dbs) {
+			return fmt.Errorf("snapshot contains database %d, but only %d databases are configured", entry.DB, len(d.dbs))
+		}
+	}
+	for _, entry := range data.Databases {
+		db := d.dbs[entry.DB]
+		db.lock()
+		db.restore(entry.snapshot)
+		db.mutex.Unlock()
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package store
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func newDatabases(n int) *Databases {
+	dbs := make([]*KeyValueStore, n)
+	for i := range dbs {
+		dbs[i] = NewKVStore()
+	}
+	return NewDatabases(dbs...)
+}
+
+func TestMoveAndSwap(t *testing.T) {
+	d := newDatabases(3)
+	d.DB(0).Set("k", "v", 3600)
+	d.DB(0).RPush("l", "a", "b")
+	d.DB(1).Set("taken", "x", 0)
+	d.DB(0).Set("taken", "y", 0)
+
+	if !d.Move("k", 0, 1) {
+		t.Fatal("Move(k) failed")
+	}
+	if _, ok, _ := d.DB(0).Get("k"); ok {
+		t.Error("k is still in db 0")
+	}
+	if value, ok, _ := d.DB(1).Get("k"); !ok || value != "v" {
+		t.Errorf("db 1 k = %q, %v", value, ok)
+	}
+	if _, ok := d.DB(1).expires["k"]; !ok {
+		t.Error("Move dropped the TTL")
+	}
+	if d.Move("taken", 0, 1) || d.Move("missing", 0, 1) || d.Move("l", 0, 0) {
+		t.Error("Move succeeded where it should not")
+	}
+	if !d.Move("l", 0, 2) || !reflect.DeepEqual(d.DB(2).keys["l"].value, []string{"a", "b"}) {
+		t.Error("Move(l) did not move the list")
+	}
+
+	db1 := d.DB(1)
+	d.Swap(1, 2)
+	if d.DB(1) != db1 {
+		t.Error("Swap replaced the store")
+	}
+	if d.DB(1).KeyCounts().Lists != 1 {
+		t.Error("db 1 does not hold db 2's list after Swap")
+	}
+	if value, _, _ := d.DB(2).Get("taken"); value != "x" {
+		t.Errorf("db 2 taken = %q after Swap", value)
+	}
+}
+
+func TestCopy(t *testing.T) {
+	d := newDatabases(2)
+	d.DB(0).RPush("l", "a")
+	d.DB(0).Set("s", "v", 3600)
+	d.DB(1).Set("s", "taken", 0)
+
+	if !d.Copy("l", 0, "l2", 0, false) {
+		t.Fatal("Copy(l) failed")
+	}
+	d.DB(0).RPush("l2", "b")
+	if n, _ := d.DB(0).RPush("l", "c"); n != 2 {
+		t.Error("the copy shares its list with the source")
+	}
+	if d.Copy("s", 0, "s", 1, false) || d.Copy("missing", 0, "x", 0, true) {
+		t.Error("Copy succeeded where it should not")
+	}
+	if !d.Copy("s", 0, "s", 1, true) {
+		t.Fatal("Copy with replace failed")
+	}
+	if value, _, _ := d.DB(1).Get("s"); value != "v" {
+		t.Errorf("db 1 s = %q", value)
+	}
+	if _, ok := d.DB(1).expires["s"]; !ok {
+		t.Error("Copy dropped the TTL")
+	}
+}
+
+func TestDatabasesSnapshotRoundTrip(t *testing.T) {
+	d := newDatabases(4)
+	d.DB(0).Set("a", "0", 0)
+	d.DB(3).Set("a", "3", 3600)
+	d.DB(3).SAdd("s", "m")
+
+	file := filepath.Join(t.TempDir(), "snapshot.json")
+	if err := d.SaveSnapshot(file); err != nil {
+		t.Fatal(err)
+	}
+	loaded := newDatabases(4)
+	if err := loaded.LoadSnapshot(file); err != nil {
+		t.Fatal(err)
+	}
+	if value, _, _ := loaded.DB(3).Get("a"); value != "3" {
+		t.Errorf("db 3 a = %q", value)
+	}
+	if _, ok := loaded.DB(3).expires["a"]; !ok {
+		t.Error("TTL in db 3 was not restored")
+	}
+	if value, _, _ := loaded.DB(0).Get("a"); value != "0" {
+		t.Errorf("db 0 a = %q", value)
+	}
+	if err := newDatabases(2).LoadSnapshot(file); err == nil {
+		t.Error("loading db 3 into 2 databases should fail")
+	}
+
+	// A single database snapshot is loaded into database 0.
+	single := NewKVStore()
+	single.Set("old", "format", 0)
+	if err := single.SaveSnapshot(file); err != nil {
+		t.Fatal(err)
+	}
+	loaded = newDatabases(2)
+	if err := loaded.LoadSnapshot(file); err != nil {
+		t.Fatal(err)
+	}
+	if value, _, _ := loaded.DB(0).Get("old"); value != "format" {
+		t.Errorf("db 0 old = %q", value)
+	}
+}
@@ -0,0 +1,77 @@
+package store
+
+import "time"
+
+// ExpireCondition restricts when Expire replaces the TTL of a key. Its
+// flags combine, and 0 sets the TTL unconditionally. A key without a TTL
+// counts as expiring never, so GT never holds for it and LT always does.
+type ExpireCondition int
+
+const (
+	// ExpireNX sets the TTL only if the key has none.
+	ExpireNX ExpireCondition = 1 << iota
+	// ExpireXX sets the TTL only if the key has one.
+	ExpireXX
+	// ExpireGT sets the TTL only if it ends later than the current one.
+	ExpireGT
+	// ExpireLT sets the TTL only if it ends sooner than the current one.
+	ExpireLT
+)
+
+// Expire makes key expire at at, if key exists and cond holds. A time that
+// has already passed deletes the key. It reports whether it changed
+// anything.
+func (kvs *KeyValueStore) Expire(key string, at time.Time, cond ExpireCondition) bool {
+	kvs.lock()
+	defer kvs.mutex.Unlock()
+
+	if obj, _ := kvs.lookupWrite(key, 0); obj == nil {
+		return false
+	}
+	current, expiring := kvs.expires[key]
+	switch {
+	case cond&ExpireNX != 0 && expiring,
+		cond&ExpireXX != 0 && !expiring,
+		cond&ExpireGT != 0 && (!expiring || !at.After(current)),
+		cond&ExpireLT != 0 && expiring && !at.Before(current):
+		return false
+	}
+
+	if !at.After(time.Now()) {
+		kvs.remove(key)
+		return true
+	}
+	kvs.setExpiry(key, at)
+	return true
+}
+
+// ExpireTime returns when key expires. expiring is false if key has no
+// TTL, and exists is false if key does not exist.
+func (kvs *KeyValueStore) ExpireTime(key string) (at time.Time, expiring, exists bool) {
+	kvs.rlock()
+	obj, expired, _ := kvs.lookupRead(key, 0)
+	if obj != nil {
+		at, expiring = kvs.expires[key]
+	}
+	kvs.mutex.RUnlock()
+
+	if expired {
+		kvs.deleteExpired(key)
+	}
+	return at, expiring, obj != nil
+}
+
+// Persist removes the TTL of key, reporting whether it had one.
+func (kvs *KeyValueStore) Persist(key string) bool {
+	kvs.lock()
+	defer kvs.mutex.Unlock()
+
+	if obj, _ := kvs.lookupWrite(key, 0); obj == nil {
+		return false
+	}
+	if _, expiring := kvs.expires[key]; !expiring {
+		return false
+	}
+	delete(kvs.expires, key)
+	return true
+}
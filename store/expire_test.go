@@ -0,0 +1,62 @@
+package store
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExpireConditions(t *testing.T) {
+	kvs := NewKVStore()
+	kvs.HSet("h", "f", "v")
+	soon, later := time.Now().Add(time.Minute), time.Now().Add(time.Hour)
+
+	for _, step := range []struct {
+		at   time.Time
+		cond ExpireCondition
+		want bool
+	}{
+		{later, ExpireXX, false},
+		{later, ExpireGT, false},
+		{later, ExpireNX, true},
+		{soon, ExpireNX, false},
+		{soon, ExpireGT, false},
+		{soon, ExpireXX | ExpireLT, true},
+		{later, ExpireLT, false},
+		{later, 0, true},
+	} {
+		if got := kvs.Expire("h", step.at, step.cond); got != step.want {
+			t.Errorf("Expire(%v, %b) = %v, want %v", step.at.Sub(soon).Round(time.Minute), step.cond, got, step.want)
+		}
+	}
+	if at, expiring, exists := kvs.ExpireTime("h"); !exists || !expiring || !at.Equal(later) {
+		t.Errorf("ExpireTime = %v, %v, %v", at, expiring, exists)
+	}
+	if !kvs.Persist("h") || kvs.Persist("h") || kvs.Expire("missing", later, 0) {
+		t.Error("Persist or Expire on a missing key misbehaved")
+	}
+	if !kvs.Expire("h", time.Now().Add(-time.Second), 0) || kvs.Exists("h") != 0 {
+		t.Error("a past expiry did not delete the key")
+	}
+}
+
+func TestExpiredCollectionsAreGone(t *testing.T) {
+	kvs := NewKVStore()
+	kvs.SAdd("s", "m")
+	kvs.HSet("h", "f", "v")
+	kvs.Expire("s", time.Now().Add(time.Millisecond), 0)
+	kvs.Expire("h", time.Now().Add(time.Millisecond), 0)
+	time.Sleep(5 * time.Millisecond)
+
+	if members, _ := kvs.SMember("s"); len(members) != 0 {
+		t.Errorf("expired set still has %q", members)
+	}
+	if _, ok, _ := kvs.HGet("h", "f"); ok {
+		t.Error("expired hash still has its field")
+	}
+	if n, _ := kvs.SAdd("s", "x"); n != 1 || kvs.Type("h") != 0 {
+		t.Error("expired keys were not deleted")
+	}
+	if _, expiring, exists := kvs.ExpireTime("s"); !exists || expiring {
+		t.Errorf("new set: expiring %v, exists %v", expiring, exists)
+	}
+}
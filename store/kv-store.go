@@ -3,17 +3,33 @@ package store
 import (
 	"container/heap"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// KeyValueStore is a single keyspace. Every key holds exactly one typed
+// object; operations on a key of another type fail with ErrWrongType.
 type KeyValueStore struct {
-	store   map[string]string
-	lists   map[string][]string
-	hashes  map[string]map[string]string
-	sets    map[string]map[string]struct{}
+	keys    map[string]*object
 	expires map[string]time.Time
 	pq      priorityQueue
-	mutex   sync.RWMutex
+	// counts is kept up to date as keys come and go, so KeyCounts does not
+	// have to walk the keyspace.
+	counts KeyCounts
+	// scanKeys orders the keys for SCAN.
+	scanKeys scanIndex
+	mutex    sync.RWMutex
+	stats    Stats
+	// latencyHook, when set, is told about internal stalls.
+	latencyHook func(event string, d time.Duration)
+}
+
+// Stats counts keyspace events for INFO. The fields are updated
+// atomically.
+type Stats struct {
+	KeyspaceHits   int64
+	KeyspaceMisses int64
+	ExpiredKeys    int64
 }
 
 type Item struct {
@@ -24,7 +40,7 @@ type Item struct {
 
 func NewKVStore() *KeyValueStore {
 	return &KeyValueStore{
-		store:   make(map[string]string),
+		keys:    make(map[string]*object),
 		expires: make(map[string]time.Time),
 		pq:      make(priorityQueue, 0),
 	}
@@ -57,184 +73,502 @@ func (pq *priorityQueue) Pop() interface{} {
 	return item
 }
 
-func (kvs *KeyValueStore) LPush(key string, values ...string) int {
-	kvs.mutex.Lock()
-	defer kvs.mutex.Unlock()
-
-	if kvs.lists == nil {
-		kvs.lists = make(map[string][]string)
+// lookupRead finds key for a read and counts the hit or miss. An expired
+// key is reported as missing, and expired is set so the caller can delete
+// it once it holds the write lock. A typ of 0 matches any type. The caller
+// holds the read lock.
+func (kvs *KeyValueStore) lookupRead(key string, typ Type) (obj *object, expired bool, err error) {
+	if expiry, ok := kvs.expires[key]; ok && time.Now().After(expiry) {
+		kvs.countLookup(false)
+		return nil, true, nil
 	}
-
-	if _, exists := kvs.lists[key]; !exists {
-		kvs.lists[key] = []string{}
+	obj = kvs.keys[key]
+	kvs.countLookup(obj != nil)
+	if obj != nil && typ != 0 && obj.typ != typ {
+		return nil, false, ErrWrongType
 	}
-
-	kvs.lists[key] = append(values, kvs.lists[key]...)
-
-	return len(kvs.lists[key])
+	return obj, false, nil
 }
 
-func (kvs *KeyValueStore) RPush(key string, values ...string) int {
-	kvs.mutex.Lock()
-	defer kvs.mutex.Unlock()
+// deleteExpired deletes key if it has expired. It takes the write lock, so
+// readers call it after releasing the read lock.
+func (kvs *KeyValueStore) deleteExpired(key string) {
+	kvs.lock()
+	kvs.expireIfNeeded(key)
+	kvs.mutex.Unlock()
+}
 
-	if kvs.lists == nil {
-		kvs.lists = make(map[string][]string)
+// lookupWrite finds key for a write, deleting it first if it has expired.
+// It returns nil if key does not exist. A typ of 0 matches any type. The
+// caller holds the write lock.
+func (kvs *KeyValueStore) lookupWrite(key string, typ Type) (*object, error) {
+	kvs.expireIfNeeded(key)
+	obj := kvs.keys[key]
+	if obj != nil && typ != 0 && obj.typ != typ {
+		return nil, ErrWrongType
 	}
+	return obj, nil
+}
 
-	if _, exists := kvs.lists[key]; !exists {
-		kvs.lists[key] = []string{}
+// add stores obj under key, replacing whatever key held but keeping its
+// TTL. The caller holds the write lock.
+func (kvs *KeyValueStore) add(key string, obj *object) {
+	if old := kvs.keys[key]; old != nil {
+		kvs.counts.add(old.typ, -1)
+	} else {
+		kvs.scanKeys.add(key)
 	}
+	kvs.keys[key] = obj
+	kvs.counts.add(obj.typ, 1)
+}
 
-	kvs.lists[key] = append(kvs.lists[key], values...)
+// remove deletes key and its TTL, reporting whether key existed. The caller
+// holds the write lock.
+func (kvs *KeyValueStore) remove(key string) bool {
+	delete(kvs.expires, key)
+	obj := kvs.keys[key]
+	if obj == nil {
+		return false
+	}
+	delete(kvs.keys, key)
+	kvs.scanKeys.remove(key)
+	kvs.counts.add(obj.typ, -1)
+	return true
+}
 
-	return len(kvs.lists[key])
+// setExpiry gives key a TTL ending at expiry. The caller holds the write
+// lock.
+func (kvs *KeyValueStore) setExpiry(key string, expiry time.Time) {
+	kvs.expires[key] = expiry
+	heap.Push(&kvs.pq, &Item{key: key, expiry: expiry})
 }
 
-func (kvs *KeyValueStore) LPop(key string) (string, bool) {
-	kvs.mutex.Lock()
+func (kvs *KeyValueStore) LPush(key string, values ...string) (int, error) {
+	kvs.lock()
 	defer kvs.mutex.Unlock()
 
-	if list, exists := kvs.lists[key]; exists && len(list) > 0 {
-		value := list[0]
-		kvs.lists[key] = list[1:]
-		return value, true
+	obj, err := kvs.lookupWrite(key, TypeList)
+	if err != nil {
+		return 0, err
+	}
+	if obj == nil {
+		obj = &object{typ: TypeList, value: []string{}}
+		kvs.add(key, obj)
 	}
+	list := append(values, obj.value.([]string)...)
+	obj.value = list
 
-	return "", false
+	return len(list), nil
 }
 
-func (kvs *KeyValueStore) RPop(key string) (string, bool) {
-	kvs.mutex.Lock()
+func (kvs *KeyValueStore) RPush(key string, values ...string) (int, error) {
+	kvs.lock()
 	defer kvs.mutex.Unlock()
 
-	if list, exists := kvs.lists[key]; exists && len(list) > 0 {
-		value := list[len(list)-1]
-		kvs.lists[key] = list[:len(list)-1]
-		return value, true
+	obj, err := kvs.lookupWrite(key, TypeList)
+	if err != nil {
+		return 0, err
 	}
+	if obj == nil {
+		obj = &object{typ: TypeList, value: []string{}}
+		kvs.add(key, obj)
+	}
+	list := append(obj.value.([]string), values...)
+	obj.value = list
 
-	return "", false
+	return len(list), nil
 }
 
-func (kvs *KeyValueStore) HSet(key, field, value string) {
-	kvs.mutex.Lock()
+func (kvs *KeyValueStore) LPop(key string) (string, bool, error) {
+	return kvs.pop(key, func(list []string) (string, []string) {
+		return list[0], list[1:]
+	})
+}
+
+func (kvs *KeyValueStore) RPop(key string) (string, bool, error) {
+	return kvs.pop(key, func(list []string) (string, []string) {
+		return list[len(list)-1], list[:len(list)-1]
+	})
+}
+
+// pop removes an element chosen by take from the list at key, deleting the
+// key when the list becomes empty.
+func (kvs *KeyValueStore) pop(key string, take func([]string) (string, []string)) (string, bool, error) {
+	kvs.lock()
 	defer kvs.mutex.Unlock()
 
-	if kvs.hashes == nil {
-		kvs.hashes = make(map[string]map[string]string)
+	obj, err := kvs.lookupWrite(key, TypeList)
+	if obj == nil || err != nil {
+		return "", false, err
 	}
-
-	if _, exists := kvs.hashes[key]; !exists {
-		kvs.hashes[key] = make(map[string]string)
+	value, rest := take(obj.value.([]string))
+	if len(rest) == 0 {
+		kvs.remove(key)
+	} else {
+		obj.value = rest
 	}
-
-	kvs.hashes[key][field] = value
+	return value, true, nil
 }
 
-func (kvs *KeyValueStore) HGet(key, field string) (string, bool) {
-	kvs.mutex.RLock()
-	defer kvs.mutex.RUnlock()
+// HSet sets field in the hash at key and returns 1 if the field is new, 0
+// if it was updated.
+func (kvs *KeyValueStore) HSet(key, field, value string) (int, error) {
+	kvs.lock()
+	defer kvs.mutex.Unlock()
 
-	if kvs.hashes == nil {
-		return "", false
+	obj, err := kvs.lookupWrite(key, TypeHash)
+	if err != nil {
+		return 0, err
+	}
+	if obj == nil {
+		obj = newObject(TypeHash, map[string]string{})
+		kvs.add(key, obj)
 	}
+	hash := obj.value.(map[string]string)
+	_, exists := hash[field]
+	hash[field] = value
+	if exists {
+		return 0, nil
+	}
+	obj.index.add(field)
+	return 1, nil
+}
 
-	if hash, exists := kvs.hashes[key]; exists {
-		value, exists := hash[field]
-		return value, exists
+func (kvs *KeyValueStore) HGet(key, field string) (string, bool, error) {
+	kvs.rlock()
+	obj, expired, err := kvs.lookupRead(key, TypeHash)
+	var value string
+	var exists bool
+	if obj != nil {
+		value, exists = obj.value.(map[string]string)[field]
 	}
+	kvs.mutex.RUnlock()
 
-	return "", false
+	if expired {
+		kvs.deleteExpired(key)
+	}
+	return value, exists, err
 }
 
-func (kvs *KeyValueStore) SAdd(key string, members ...string) int {
-	kvs.mutex.Lock()
+func (kvs *KeyValueStore) SAdd(key string, members ...string) (int, error) {
+	kvs.lock()
 	defer kvs.mutex.Unlock()
 
-	if _, exists := kvs.sets[key]; !exists {
-		kvs.sets[key] = map[string]struct{}{}
+	obj, err := kvs.lookupWrite(key, TypeSet)
+	if err != nil {
+		return 0, err
+	}
+	if obj == nil {
+		obj = newObject(TypeSet, map[string]struct{}{})
+		kvs.add(key, obj)
 	}
 
+	set := obj.value.(map[string]struct{})
 	added := 0
 	for _, member := range members {
-		if _, exists := kvs.sets[key][member]; !exists {
-			kvs.sets[key][member] = struct{}{}
+		if _, exists := set[member]; !exists {
+			set[member] = struct{}{}
+			obj.index.add(member)
 			added++
 		}
 	}
 
-	return added
+	return added, nil
 }
 
-func (kvs *KeyValueStore) SRem(key string, members ...string) int {
-	kvs.mutex.Lock()
+func (kvs *KeyValueStore) SRem(key string, members ...string) (int, error) {
+	kvs.lock()
 	defer kvs.mutex.Unlock()
 
+	obj, err := kvs.lookupWrite(key, TypeSet)
+	if obj == nil || err != nil {
+		return 0, err
+	}
+
+	set := obj.value.(map[string]struct{})
 	removed := 0
-	if set, exists := kvs.sets[key]; exists {
-		for _, member := range members {
-			if _, exists := set[member]; exists {
-				delete(set, member)
-				removed++
-			}
+	for _, member := range members {
+		if _, exists := set[member]; exists {
+			delete(set, member)
+			obj.index.remove(member)
+			removed++
 		}
 	}
+	if len(set) == 0 {
+		kvs.remove(key)
+	}
 
-	return removed
+	return removed, nil
 }
 
-func (kvs *KeyValueStore) SMember(key string) []string {
-	kvs.mutex.RLock()
-	defer kvs.mutex.RUnlock()
-
-	if set, exists := kvs.sets[key]; exists {
-		members := []string{}
-		for member := range set {
+func (kvs *KeyValueStore) SMember(key string) ([]string, error) {
+	kvs.rlock()
+	obj, expired, err := kvs.lookupRead(key, TypeSet)
+	var members []string
+	if obj != nil {
+		members = make([]string, 0, len(obj.value.(map[string]struct{})))
+		for member := range obj.value.(map[string]struct{}) {
 			members = append(members, member)
 		}
-		return members
 	}
-	return nil
+	kvs.mutex.RUnlock()
+
+	if expired {
+		kvs.deleteExpired(key)
+	}
+	return members, err
+}
+
+func (kvs *KeyValueStore) Get(key string) (string, bool, error) {
+	kvs.rlock()
+	obj, expired, err := kvs.lookupRead(key, TypeString)
+	var value string
+	if obj != nil {
+		value = obj.value.(string)
+	}
+	kvs.mutex.RUnlock()
+
+	if expired {
+		kvs.deleteExpired(key)
+	}
+	return value, obj != nil, err
+}
+
+// SetLatencyHook makes the store report stalls to hook: time spent waiting
+// for its lock as "lock-contention", and time a snapshot holds the lock as
+// "snapshot-lock". It must be called before the store is shared.
+func (kvs *KeyValueStore) SetLatencyHook(hook func(event string, d time.Duration)) {
+	kvs.latencyHook = hook
+}
+
+// lock takes the write lock. Only when another goroutine holds the lock is
+// the wait timed, so the uncontended path stays cheap.
+func (kvs *KeyValueStore) lock() {
+	if kvs.mutex.TryLock() {
+		return
+	}
+	start := time.Now()
+	kvs.mutex.Lock()
+	kvs.reportLatency("lock-contention", time.Since(start))
 }
 
-func (kvs *KeyValueStore) Get(key string) (string, bool) {
+func (kvs *KeyValueStore) rlock() {
+	if kvs.mutex.TryRLock() {
+		return
+	}
+	start := time.Now()
 	kvs.mutex.RLock()
-	defer kvs.mutex.RUnlock()
+	kvs.reportLatency("lock-contention", time.Since(start))
+}
 
-	if expiry, exists := kvs.expires[key]; exists && time.Now().After(expiry) {
-		kvs.Del(key)
-		return "", false
+func (kvs *KeyValueStore) reportLatency(event string, d time.Duration) {
+	if kvs.latencyHook != nil {
+		kvs.latencyHook(event, d)
 	}
+}
+
+func (kvs *KeyValueStore) countLookup(hit bool) {
+	if hit {
+		atomic.AddInt64(&kvs.stats.KeyspaceHits, 1)
+	} else {
+		atomic.AddInt64(&kvs.stats.KeyspaceMisses, 1)
+	}
+}
+
+// expireIfNeeded deletes key if its TTL has passed. The caller holds the
+// write lock.
+func (kvs *KeyValueStore) expireIfNeeded(key string) bool {
+	expiry, exists := kvs.expires[key]
+	if !exists || !time.Now().After(expiry) {
+		return false
+	}
+	kvs.remove(key)
+	atomic.AddInt64(&kvs.stats.ExpiredKeys, 1)
+	return true
+}
+
+func (kvs *KeyValueStore) Stats() Stats {
+	return Stats{
+		KeyspaceHits:   atomic.LoadInt64(&kvs.stats.KeyspaceHits),
+		KeyspaceMisses: atomic.LoadInt64(&kvs.stats.KeyspaceMisses),
+		ExpiredKeys:    atomic.LoadInt64(&kvs.stats.ExpiredKeys),
+	}
+}
+
+func (kvs *KeyValueStore) ResetStats() {
+	atomic.StoreInt64(&kvs.stats.KeyspaceHits, 0)
+	atomic.StoreInt64(&kvs.stats.KeyspaceMisses, 0)
+	atomic.StoreInt64(&kvs.stats.ExpiredKeys, 0)
+}
+
+// KeyCounts is the number of keys of each type.
+type KeyCounts struct {
+	Strings int
+	Lists   int
+	Hashes  int
+	Sets    int
+}
+
+func (kvs *KeyValueStore) KeyCounts() KeyCounts {
+	kvs.rlock()
+	defer kvs.mutex.RUnlock()
+	return kvs.counts
+}
+
+// KeyspaceInfo returns the number of keys, how many of them have a TTL
+// and their average remaining TTL, as INFO keyspace reports them.
+func (kvs *KeyValueStore) KeyspaceInfo() (keys, expires int, avgTTL time.Duration) {
+	kvs.rlock()
+	defer kvs.mutex.RUnlock()
 
-	value, exists := kvs.store[key]
-	return value, exists
+	keys = len(kvs.keys)
+	now := time.Now()
+	var total time.Duration
+	for _, expiry := range kvs.expires {
+		if ttl := expiry.Sub(now); ttl > 0 {
+			total += ttl
+			expires++
+		}
+	}
+	if expires > 0 {
+		avgTTL = total / time.Duration(expires)
+	}
+	return keys, expires, avgTTL
 }
 
 func (kvs *KeyValueStore) Set(key, value string, ttl int) {
-	kvs.mutex.Lock()
+	kvs.lock()
 	defer kvs.mutex.Unlock()
 
-	kvs.store[key] = value
+	// SET replaces a value of any type.
+	kvs.expireIfNeeded(key)
+	kvs.add(key, &object{typ: TypeString, value: value})
 
 	if ttl > 0 {
-		expiry := time.Now().Add(time.Duration(ttl) * time.Second)
-		kvs.expires[key] = expiry
-		heap.Push(&kvs.pq, &Item{key: key, expiry: expiry})
+		kvs.setExpiry(key, time.Now().Add(time.Duration(ttl)*time.Second))
 	} else {
 		delete(kvs.expires, key)
 	}
 }
 
-func (kvs *KeyValueStore) Del(key string) {
-	kvs.mutex.Lock()
+// Del deletes keys of any type and returns how many of them existed.
+func (kvs *KeyValueStore) Del(keys ...string) int {
+	kvs.lock()
 	defer kvs.mutex.Unlock()
-	delete(kvs.store, key)
-	delete(kvs.expires, key)
+
+	deleted := 0
+	for _, key := range keys {
+		if obj, _ := kvs.lookupWrite(key, 0); obj != nil {
+			kvs.remove(key)
+			deleted++
+		}
+	}
+	return deleted
+}
+
+// Exists returns how many of keys exist. A key given twice is counted
+// twice.
+func (kvs *KeyValueStore) Exists(keys ...string) int {
+	kvs.lock()
+	defer kvs.mutex.Unlock()
+
+	count := 0
+	for _, key := range keys {
+		obj, _ := kvs.lookupWrite(key, 0)
+		kvs.countLookup(obj != nil)
+		if obj != nil {
+			count++
+		}
+	}
+	return count
+}
+
+// Type returns the type of the value at key, or 0 if key does not exist.
+func (kvs *KeyValueStore) Type(key string) Type {
+	kvs.rlock()
+	obj, expired, _ := kvs.lookupRead(key, 0)
+	var typ Type
+	if obj != nil {
+		typ = obj.typ
+	}
+	kvs.mutex.RUnlock()
+
+	if expired {
+		kvs.deleteExpired(key)
+	}
+	return typ
+}
+
+// Rename moves the value and TTL of src to dst, replacing dst unless nx is
+// set. It returns false if nx is set and dst exists, and ErrNoSuchKey if
+// src does not exist.
+func (kvs *KeyValueStore) Rename(src, dst string, nx bool) (bool, error) {
+	kvs.lock()
+	defer kvs.mutex.Unlock()
+
+	obj, _ := kvs.lookupWrite(src, 0)
+	if obj == nil {
+		return false, ErrNoSuchKey
+	}
+	if src == dst {
+		return !nx, nil
+	}
+	if nx && kvs.exists(dst) {
+		return false, nil
+	}
+	expiry, expiring := kvs.expires[src]
+	kvs.remove(src)
+	kvs.remove(dst)
+	kvs.add(dst, obj)
+	if expiring {
+		kvs.setExpiry(dst, expiry)
+	}
+	return true, nil
+}
+
+// RandomKey returns a key chosen at random, skipping and deleting expired
+// keys on the way. It returns false if the database is empty.
+func (kvs *KeyValueStore) RandomKey() (string, bool) {
+	kvs.lock()
+	defer kvs.mutex.Unlock()
+
+	for key := range kvs.keys {
+		if !kvs.expireIfNeeded(key) {
+			return key, true
+		}
+	}
+	return "", false
+}
+
+// DBSize returns the number of keys, including expired keys that have not
+// been deleted yet.
+func (kvs *KeyValueStore) DBSize() int {
+	kvs.rlock()
+	defer kvs.mutex.RUnlock()
+	return len(kvs.keys)
+}
+
+// Flush deletes every key. The old maps are left to the garbage collector,
+// so the lock is only held for as long as it takes to replace them.
+func (kvs *KeyValueStore) Flush() {
+	kvs.lock()
+	defer kvs.mutex.Unlock()
+	kvs.keys = make(map[string]*object)
+	kvs.expires = make(map[string]time.Time)
+	kvs.pq = make(priorityQueue, 0)
+	kvs.counts = KeyCounts{}
+	kvs.scanKeys = scanIndex{}
+}
+
+// exists reports whether key holds a value of any type, deleting it first
+// if it has expired. The caller holds the write lock.
+func (kvs *KeyValueStore) exists(key string) bool {
+	obj, _ := kvs.lookupWrite(key, 0)
+	return obj != nil
 }
 
 func (store *KeyValueStore) CleanupExpiredKeys() {
-	store.mutex.Lock()
+	store.lock()
 	defer store.mutex.Unlock()
 
 	for store.pq.Len() > 0 {
@@ -242,7 +576,11 @@ func (store *KeyValueStore) CleanupExpiredKeys() {
 		if item.expiry.After(time.Now()) {
 			break
 		}
-		store.Del(item.key)
 		heap.Pop(&store.pq)
+		// The queue keeps stale items for keys whose TTL was changed or
+		// removed; only the current expiry counts.
+		if expiry, exists := store.expires[item.key]; exists && expiry.Equal(item.expiry) {
+			store.expireIfNeeded(item.key)
+		}
 	}
 }
@@ -0,0 +1,76 @@
+package store
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestKeysHoldOneType(t *testing.T) {
+	kvs := NewKVStore()
+	kvs.Set("s", "v", 0)
+	kvs.RPush("l", "a")
+
+	if _, err := kvs.RPush("s", "x"); !errors.Is(err, ErrWrongType) {
+		t.Errorf("RPush on a string: %v", err)
+	}
+	if _, err := kvs.SAdd("l", "m"); !errors.Is(err, ErrWrongType) {
+		t.Errorf("SAdd on a list: %v", err)
+	}
+	if _, _, err := kvs.Get("l"); !errors.Is(err, ErrWrongType) {
+		t.Errorf("Get on a list: %v", err)
+	}
+	if _, _, err := kvs.HGet("s", "f"); !errors.Is(err, ErrWrongType) {
+		t.Errorf("HGet on a string: %v", err)
+	}
+
+	kvs.Set("l", "now a string", 0)
+	if value, ok, err := kvs.Get("l"); !ok || err != nil || value != "now a string" {
+		t.Errorf("Get after SET over a list = %q, %v, %v", value, ok, err)
+	}
+	if counts := kvs.KeyCounts(); counts != (KeyCounts{Strings: 2}) {
+		t.Errorf("KeyCounts = %+v", counts)
+	}
+}
+
+func TestDelAndEmptyCollections(t *testing.T) {
+	kvs := NewKVStore()
+	kvs.Set("s", "v", 0)
+	kvs.HSet("h", "f", "v")
+	kvs.SAdd("set", "m")
+	kvs.RPush("l", "a")
+
+	if n := kvs.Del("s", "h", "missing"); n != 2 {
+		t.Errorf("Del = %d, want 2", n)
+	}
+	kvs.RPop("l")
+	kvs.SRem("set", "m")
+	if len(kvs.keys) != 0 || kvs.KeyCounts() != (KeyCounts{}) {
+		t.Errorf("keys left behind: %v, %+v", kvs.keys, kvs.KeyCounts())
+	}
+	if n, err := kvs.SAdd("l", "m"); n != 1 || err != nil {
+		t.Errorf("SAdd on a popped list key = %d, %v", n, err)
+	}
+}
+
+func TestRenameKeepsTTL(t *testing.T) {
+	kvs := NewKVStore()
+	kvs.Set("src", "v", 3600)
+	kvs.Set("dst", "old", 0)
+	kvs.SAdd("taken", "m")
+
+	if ok, err := kvs.Rename("src", "dst", false); !ok || err != nil {
+		t.Fatalf("Rename = %v, %v", ok, err)
+	}
+	if _, ok := kvs.expires["dst"]; !ok || kvs.Exists("src") != 0 {
+		t.Error("Rename did not move the TTL")
+	}
+	if ok, err := kvs.Rename("dst", "taken", true); ok || err != nil {
+		t.Errorf("RENAMENX onto an existing key = %v, %v", ok, err)
+	}
+	if _, err := kvs.Rename("missing", "x", false); !errors.Is(err, ErrNoSuchKey) {
+		t.Errorf("Rename of a missing key: %v", err)
+	}
+	if typ := kvs.Type("taken"); typ != TypeSet {
+		t.Errorf("Type = %v", typ)
+	}
+}
@@ -0,0 +1,109 @@
+package store
+
+import "errors"
+
+// ErrWrongType is returned by operations on a key holding a value of
+// another type. Its text is the reply Redis sends, without the leading "-".
+var ErrWrongType = errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+
+// ErrNoSuchKey is returned by operations whose source key does not exist.
+var ErrNoSuchKey = errors.New("ERR no such key")
+
+// Type is the type of the value a key holds.
+type Type int
+
+const (
+	TypeString Type = iota + 1
+	TypeList
+	TypeHash
+	TypeSet
+)
+
+// String returns the name TYPE reports for t.
+func (t Type) String() string {
+	switch t {
+	case TypeString:
+		return "string"
+	case TypeList:
+		return "list"
+	case TypeHash:
+		return "hash"
+	case TypeSet:
+		return "set"
+	}
+	return "none"
+}
+
+// ParseType returns the type named name, as String names it.
+func ParseType(name string) (Type, bool) {
+	for typ := TypeString; typ <= TypeSet; typ++ {
+		if typ.String() == name {
+			return typ, true
+		}
+	}
+	return 0, false
+}
+
+// object is the value of a key. value is a string, []string,
+// map[string]string or map[string]struct{}, depending on typ. Collections
+// are never empty: the key is deleted together with the last element.
+type object struct {
+	typ   Type
+	value any
+	// index holds the fields of a hash or the members of a set for
+	// HSCAN and SSCAN. It is nil for other types.
+	index *scanIndex
+}
+
+// newObject returns an object holding value, indexing it if it is a hash
+// or a set.
+func newObject(typ Type, value any) *object {
+	obj := &object{typ: typ, value: value}
+	switch value := value.(type) {
+	case map[string]string:
+		obj.index = &scanIndex{}
+		for field := range value {
+			obj.index.add(field)
+		}
+	case map[string]struct{}:
+		obj.index = &scanIndex{}
+		for member := range value {
+			obj.index.add(member)
+		}
+	}
+	return obj
+}
+
+// clone returns a deep copy of obj.
+func (obj *object) clone() *object {
+	switch value := obj.value.(type) {
+	case []string:
+		return &object{typ: obj.typ, value: append([]string(nil), value...)}
+	case map[string]string:
+		hash := make(map[string]string, len(value))
+		for field, v := range value {
+			hash[field] = v
+		}
+		return newObject(obj.typ, hash)
+	case map[string]struct{}:
+		set := make(map[string]struct{}, len(value))
+		for member := range value {
+			set[member] = struct{}{}
+		}
+		return newObject(obj.typ, set)
+	}
+	return &object{typ: obj.typ, value: obj.value}
+}
+
+func (c *KeyCounts) add(typ Type, n int) {
+	switch typ {
+	case TypeString:
+		c.Strings += n
+	case TypeList:
+		c.Lists += n
+	case TypeHash:
+		c.Hashes += n
+	case TypeSet:
+		c.Sets += n
+	}
+}
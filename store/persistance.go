@@ -3,30 +3,119 @@ package store
 import (
 	"encoding/json"
 	"os"
+	"path/filepath"
 	"time"
 )
 
-func (kvs *KeyValueStore) SaveSnapshot(fileName string) error {
-	kvs.mutex.RLock()
-	defer kvs.mutex.RUnlock()
+// Version 2 snapshots store every key, value and member as []byte, which
+// encoding/json writes as base64, so binary data survives unchanged.
+// Snapshots without a version field are the original format, whose plain
+// JSON strings cannot represent invalid UTF-8.
+const snapshotVersion = 2
 
-	data := map[string]interface{}{
-		"store":  kvs.store,
-		"hashes": kvs.hashes,
-		"lists":  kvs.lists,
-		"sets":   kvs.sets,
-	}
+type snapshot struct {
+	Version int           `json:"version,omitempty"`
+	Strings []stringEntry `json:"strings"`
+	Lists   []listEntry   `json:"lists"`
+	Hashes  []hashEntry   `json:"hashes"`
+	Sets    []setEntry    `json:"sets"`
+	Expires []expireEntry `json:"expires"`
+}
+
+type stringEntry struct {
+	Key   []byte `json:"key"`
+	Value []byte `json:"value"`
+}
+
+type listEntry struct {
+	Key   []byte   `json:"key"`
+	Items [][]byte `json:"items"`
+}
+
+type hashEntry struct {
+	Key    []byte   `json:"key"`
+	Fields [][]byte `json:"fields"`
+	Values [][]byte `json:"values"`
+}
 
+type setEntry struct {
+	Key     []byte   `json:"key"`
+	Members [][]byte `json:"members"`
+}
+
+type expireEntry struct {
+	Key []byte `json:"key"`
+	At  int64  `json:"at"`
+}
+
+func (kvs *KeyValueStore) SaveSnapshot(fileName string) error {
+	kvs.rlock()
+	locked := time.Now()
+	data := kvs.dump()
+	kvs.mutex.RUnlock()
+	kvs.reportLatency("snapshot-lock", time.Since(locked))
+
+	data.Version = snapshotVersion
 	dataBytes, err := json.Marshal(data)
 	if err != nil {
 		return err
 	}
+	return writeFile(fileName, dataBytes)
+}
+
+// dump copies the dataset into a snapshot. The caller holds the read lock.
+func (kvs *KeyValueStore) dump() snapshot {
+	data := snapshot{}
+	for key, obj := range kvs.keys {
+		switch value := obj.value.(type) {
+		case string:
+			data.Strings = append(data.Strings, stringEntry{Key: []byte(key), Value: []byte(value)})
+		case []string:
+			data.Lists = append(data.Lists, listEntry{Key: []byte(key), Items: toBytes(value)})
+		case map[string]string:
+			entry := hashEntry{Key: []byte(key)}
+			for field, value := range value {
+				entry.Fields = append(entry.Fields, []byte(field))
+				entry.Values = append(entry.Values, []byte(value))
+			}
+			data.Hashes = append(data.Hashes, entry)
+		case map[string]struct{}:
+			entry := setEntry{Key: []byte(key)}
+			for member := range value {
+				entry.Members = append(entry.Members, []byte(member))
+			}
+			data.Sets = append(data.Sets, entry)
+		}
+	}
+	for key, expiry := range kvs.expires {
+		data.Expires = append(data.Expires, expireEntry{Key: []byte(key), At: expiry.UnixMilli()})
+	}
+	return data
+}
 
-	return os.WriteFile(fileName, dataBytes, 0644)
+func writeFile(fileName string, dataBytes []byte) error {
+	// Write to a temporary file first so a crash mid-write never leaves a
+	// truncated snapshot behind.
+	tmp, err := os.CreateTemp(filepath.Dir(fileName), ".snapshot-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(dataBytes); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), fileName)
 }
 
 func (kvs *KeyValueStore) LoadSnapshot(fileName string) error {
-	kvs.mutex.Lock()
+	kvs.lock()
 	defer kvs.mutex.Unlock()
 
 	file, err := os.ReadFile(fileName)
@@ -37,22 +126,85 @@ func (kvs *KeyValueStore) LoadSnapshot(fileName string) error {
 		return err
 	}
 
-	if kvs.store == nil {
-		kvs.store = make(map[string]string)
+	return kvs.load(file)
+}
+
+// load reads a version 1 or 2 snapshot into the store. The caller holds the
+// write lock.
+func (kvs *KeyValueStore) load(file []byte) error {
+	if kvs.keys == nil {
+		kvs.keys = make(map[string]*object)
 	}
-	if kvs.hashes == nil {
-		kvs.hashes = make(map[string]map[string]string)
+	if kvs.expires == nil {
+		kvs.expires = make(map[string]time.Time)
 	}
-	if kvs.lists == nil {
-		kvs.lists = make(map[string][]string)
+
+	var header struct {
+		Version int `json:"version"`
 	}
-	if kvs.sets == nil {
-		kvs.sets = make(map[string]map[string]struct{})
+	if err := json.Unmarshal(file, &header); err != nil {
+		return err
 	}
-	if kvs.expires == nil {
-		kvs.expires = make(map[string]time.Time)
+	if header.Version == 0 {
+		return kvs.loadLegacySnapshot(file)
 	}
 
+	data := snapshot{}
+	if err := json.Unmarshal(file, &data); err != nil {
+		return err
+	}
+	kvs.restore(data)
+	return nil
+}
+
+// restore adds the contents of a snapshot to the store. The caller holds
+// the write lock.
+func (kvs *KeyValueStore) restore(data snapshot) {
+	for _, entry := range data.Strings {
+		kvs.add(string(entry.Key), &object{typ: TypeString, value: string(entry.Value)})
+	}
+	for _, entry := range data.Lists {
+		kvs.restoreCollection(string(entry.Key), TypeList, fromBytes(entry.Items), len(entry.Items))
+	}
+	for _, entry := range data.Hashes {
+		hash := make(map[string]string, len(entry.Fields))
+		for i, field := range entry.Fields {
+			if i < len(entry.Values) {
+				hash[string(field)] = string(entry.Values[i])
+			}
+		}
+		kvs.restoreCollection(string(entry.Key), TypeHash, hash, len(hash))
+	}
+	for _, entry := range data.Sets {
+		set := make(map[string]struct{}, len(entry.Members))
+		for _, member := range entry.Members {
+			set[string(member)] = struct{}{}
+		}
+		kvs.restoreCollection(string(entry.Key), TypeSet, set, len(set))
+	}
+	for _, entry := range data.Expires {
+		kvs.restoreExpiry(string(entry.Key), time.UnixMilli(entry.At))
+	}
+}
+
+// restoreCollection adds a list, hash or set of size elements. Older
+// snapshots may hold empty collections, which are dropped, and the same key
+// under several types, where the last one loaded wins.
+func (kvs *KeyValueStore) restoreCollection(key string, typ Type, value any, size int) {
+	if size > 0 {
+		kvs.add(key, newObject(typ, value))
+	}
+}
+
+// restoreExpiry sets the TTL of a restored key. TTLs of keys that were not
+// restored are dropped.
+func (kvs *KeyValueStore) restoreExpiry(key string, expiry time.Time) {
+	if kvs.keys[key] != nil {
+		kvs.setExpiry(key, expiry)
+	}
+}
+
+func (kvs *KeyValueStore) loadLegacySnapshot(file []byte) error {
 	snapshot := map[string]interface{}{}
 	if err := json.Unmarshal(file, &snapshot); err != nil {
 		return err
@@ -60,7 +212,7 @@ func (kvs *KeyValueStore) LoadSnapshot(fileName string) error {
 
 	if storeData, ok := snapshot["store"].(map[string]interface{}); ok {
 		for key, value := range storeData {
-			kvs.store[key] = value.(string)
+			kvs.add(key, &object{typ: TypeString, value: value.(string)})
 		}
 	}
 
@@ -70,7 +222,7 @@ func (kvs *KeyValueStore) LoadSnapshot(fileName string) error {
 			for _, item := range value.([]interface{}) {
 				list = append(list, item.(string))
 			}
-			kvs.lists[key] = list
+			kvs.restoreCollection(key, TypeList, list, len(list))
 		}
 	}
 
@@ -80,27 +232,46 @@ func (kvs *KeyValueStore) LoadSnapshot(fileName string) error {
 			for field, val := range value.(map[string]interface{}) {
 				hash[field] = val.(string)
 			}
-			kvs.hashes[key] = hash
+			kvs.restoreCollection(key, TypeHash, hash, len(hash))
 		}
 	}
 
+	// Sets were written as JSON objects with empty values.
 	if setData, ok := snapshot["sets"].(map[string]interface{}); ok {
 		for key, value := range setData {
 			set := map[string]struct{}{}
-			for _, member := range value.([]interface{}) {
-				set[member.(string)] = struct{}{}
+			if members, ok := value.(map[string]interface{}); ok {
+				for member := range members {
+					set[member] = struct{}{}
+				}
 			}
-			kvs.sets[key] = set
+			kvs.restoreCollection(key, TypeSet, set, len(set))
 		}
 	}
 
 	if expiryData, ok := snapshot["expires"].(map[string]interface{}); ok {
 		for key, value := range expiryData {
 			if expiry, err := time.Parse(time.RFC3339, value.(string)); err == nil {
-				kvs.expires[key] = expiry
+				kvs.restoreExpiry(key, expiry)
 			}
 		}
 	}
 
 	return nil
 }
+
+func toBytes(values []string) [][]byte {
+	result := make([][]byte, len(values))
+	for i, value := range values {
+		result[i] = []byte(value)
+	}
+	return result
+}
+
+func fromBytes(values [][]byte) []string {
+	result := make([]string, len(values))
+	for i, value := range values {
+		result[i] = string(value)
+	}
+	return result
+}
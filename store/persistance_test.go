@@ -0,0 +1,71 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestSnapshotRoundTripIsBinarySafe(t *testing.T) {
+	binary := []string{"", "\x00", "a\r\nb", "\xff\xfe\x00\x01", "plain"}
+	key := "k\x00\xff\r\n"
+
+	kvs := NewKVStore()
+	for i, value := range binary {
+		kvs.Set(key+string(rune('a'+i)), value, 0)
+	}
+	kvs.Set("ttl\xff", "\x80", 3600)
+	kvs.RPush(key+"list", binary...)
+	for _, value := range binary {
+		kvs.HSet(key+"hash", "f"+value, value)
+	}
+	kvs.SAdd(key+"set", binary...)
+
+	file := filepath.Join(t.TempDir(), "snapshot.json")
+	if err := kvs.SaveSnapshot(file); err != nil {
+		t.Fatalf("SaveSnapshot: %v", err)
+	}
+
+	loaded := NewKVStore()
+	if err := loaded.LoadSnapshot(file); err != nil {
+		t.Fatalf("LoadSnapshot: %v", err)
+	}
+
+	if !reflect.DeepEqual(loaded.keys, kvs.keys) {
+		t.Errorf("keys = %v, want %v", loaded.keys, kvs.keys)
+	}
+	if loaded.KeyCounts() != kvs.KeyCounts() {
+		t.Errorf("key counts = %+v, want %+v", loaded.KeyCounts(), kvs.KeyCounts())
+	}
+	got, _ := loaded.SMember(key + "set")
+	want, _ := kvs.SMember(key + "set")
+	sort.Strings(got)
+	sort.Strings(want)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("set members = %q, want %q", got, want)
+	}
+	if !loaded.expires["ttl\xff"].Equal(kvs.expires["ttl\xff"].Truncate(1e6)) {
+		t.Errorf("expiry = %v, want %v", loaded.expires["ttl\xff"], kvs.expires["ttl\xff"])
+	}
+}
+
+func TestLoadLegacySnapshot(t *testing.T) {
+	legacy := `{"store":{"foo":"bar"},"lists":{"l":["a","b"]},"hashes":{"h":{"f":"v"}},"sets":{"s":{"m":{}}}}`
+	file := filepath.Join(t.TempDir(), "snapshot.json")
+	if err := os.WriteFile(file, []byte(legacy), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	kvs := NewKVStore()
+	if err := kvs.LoadSnapshot(file); err != nil {
+		t.Fatalf("LoadSnapshot: %v", err)
+	}
+	if value, _, _ := kvs.Get("foo"); value != "bar" {
+		t.Errorf("foo = %q, want %q", value, "bar")
+	}
+	if members, _ := kvs.SMember("s"); !reflect.DeepEqual(members, []string{"m"}) {
+		t.Errorf("set members = %q, want [m]", members)
+	}
+}
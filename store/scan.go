@@ -0,0 +1,207 @@
+package store
+
+import (
+	"mini-redis/glob"
+	"slices"
+	"time"
+)
+
+// A scan visits elements in the order of scanHash, and its cursor is the
+// hash the next batch starts at. Because an element's hash never changes,
+// an element that exists for the whole scan is returned exactly once, no
+// matter how the data changes in between; elements added or removed during
+// the scan may or may not be returned. Cursor 0 starts a scan, and a
+// returned cursor of 0 ends it.
+
+// scanHash is 64-bit FNV-1a followed by the MurmurHash3 finalizer, which
+// spreads similar names over the top bits the buckets of a scanIndex use.
+// It is fixed, so cursors stay valid across restarts.
+func scanHash(s string) uint64 {
+	hash := uint64(14695981039346656037)
+	for i := 0; i < len(s); i++ {
+		hash ^= uint64(s[i])
+		hash *= 1099511628211
+	}
+	hash ^= hash >> 33
+	hash *= 0xff51afd7ed558ccd
+	hash ^= hash >> 33
+	hash *= 0xc4ceb9fe1a85ec53
+	hash ^= hash >> 33
+	return hash
+}
+
+// scanIndex keeps the names of a collection in buckets by the top bits of
+// their scanHash, so a scan starts at the bucket its cursor falls in and
+// costs about COUNT rather than the size of the collection. Buckets are
+// sorted, so the layout does not depend on the order of writes. It is
+// updated on every write, and the zero value is an empty index.
+type scanIndex struct {
+	buckets [][]string
+	// shift turns a hash into its bucket: 64 minus log2(len(buckets)).
+	shift uint
+	size  int
+}
+
+func (idx *scanIndex) add(name string) {
+	if len(idx.buckets) == 0 {
+		idx.buckets, idx.shift = make([][]string, 1), 64
+	}
+	insertSorted(idx.buckets, idx.shift, name)
+	idx.size++
+	if idx.size > 2*len(idx.buckets) {
+		idx.resize(idx.shift - 1)
+	}
+}
+
+func (idx *scanIndex) remove(name string) {
+	if len(idx.buckets) == 0 {
+		return
+	}
+	i := scanHash(name) >> idx.shift
+	if j, found := slices.BinarySearch(idx.buckets[i], name); found {
+		idx.buckets[i] = slices.Delete(idx.buckets[i], j, j+1)
+		idx.size--
+	}
+	if len(idx.buckets) > 1 && idx.size < len(idx.buckets)/8 {
+		idx.resize(idx.shift + 1)
+	}
+}
+
+// resize rebuckets every name. Buckets double once they hold two names on
+// average and halve when mostly empty, so both cost O(1) amortized.
+func (idx *scanIndex) resize(shift uint) {
+	buckets := make([][]string, 1<<(64-shift))
+	for _, bucket := range idx.buckets {
+		for _, name := range bucket {
+			insertSorted(buckets, shift, name)
+		}
+	}
+	idx.buckets, idx.shift = buckets, shift
+}
+
+// insertSorted adds name to its bucket in buckets, keeping the bucket sorted.
+func insertSorted(buckets [][]string, shift uint, name string) {
+	i := scanHash(name) >> shift
+	j, _ := slices.BinarySearch(buckets[i], name)
+	buckets[i] = slices.Insert(buckets[i], j, name)
+}
+
+// scan returns the batch of at least count names that starts at cursor,
+// and the cursor of the next batch. Batches end on a bucket boundary, so
+// names sharing a hash are never split across two batches.
+func (idx *scanIndex) scan(cursor uint64, count int) ([]string, uint64) {
+	names := []string{}
+	if len(idx.buckets) == 0 {
+		return names, 0
+	}
+	for i := cursor >> idx.shift; i < uint64(len(idx.buckets)); i++ {
+		for _, name := range idx.buckets[i] {
+			// Only the first bucket can hold names before the cursor.
+			if i > cursor>>idx.shift || scanHash(name) >= cursor {
+				names = append(names, name)
+			}
+		}
+		if len(names) >= count {
+			// Past the last bucket the cursor wraps to 0, ending the scan.
+			return names, (i + 1) << idx.shift
+		}
+	}
+	return names, 0
+}
+
+// matches reports whether name matches pattern; an empty pattern matches
+// everything.
+func matches(pattern, name string) bool {
+	return pattern == "" || glob.Match(pattern, name)
+}
+
+// Scan returns a batch of keys starting at cursor, and the cursor of the
+// next batch. count is how many keys to look at; those that have expired,
+// do not match pattern or, when typ is not 0, are not of type typ are
+// left out.
+func (kvs *KeyValueStore) Scan(cursor uint64, count int, pattern string, typ Type) ([]string, uint64) {
+	kvs.rlock()
+	defer kvs.mutex.RUnlock()
+
+	names, next := kvs.scanKeys.scan(cursor, count)
+	now := time.Now()
+	keys := names[:0]
+	for _, key := range names {
+		if expiry, ok := kvs.expires[key]; ok && now.After(expiry) {
+			continue
+		}
+		if (typ == 0 || kvs.keys[key].typ == typ) && matches(pattern, key) {
+			keys = append(keys, key)
+		}
+	}
+	return keys, next
+}
+
+// Keys returns every key matching pattern that has not expired.
+func (kvs *KeyValueStore) Keys(pattern string) []string {
+	kvs.rlock()
+	defer kvs.mutex.RUnlock()
+
+	now := time.Now()
+	keys := []string{}
+	for key := range kvs.keys {
+		if expiry, ok := kvs.expires[key]; ok && now.After(expiry) {
+			continue
+		}
+		if matches(pattern, key) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// SScan is Scan over the members of the set at key.
+func (kvs *KeyValueStore) SScan(key string, cursor uint64, count int, pattern string) ([]string, uint64, error) {
+	kvs.rlock()
+	obj, expired, err := kvs.lookupRead(key, TypeSet)
+	var members []string
+	var next uint64
+	if obj != nil {
+		members, next = obj.index.scan(cursor, count)
+		members = filterMatches(members, pattern)
+	}
+	kvs.mutex.RUnlock()
+
+	if expired {
+		kvs.deleteExpired(key)
+	}
+	return members, next, err
+}
+
+// HScan is Scan over the fields of the hash at key. It returns the fields
+// that match pattern, each followed by its value.
+func (kvs *KeyValueStore) HScan(key string, cursor uint64, count int, pattern string) ([]string, uint64, error) {
+	kvs.rlock()
+	obj, expired, err := kvs.lookupRead(key, TypeHash)
+	var pairs []string
+	var next uint64
+	if obj != nil {
+		hash := obj.value.(map[string]string)
+		var fields []string
+		fields, next = obj.index.scan(cursor, count)
+		for _, field := range filterMatches(fields, pattern) {
+			pairs = append(pairs, field, hash[field])
+		}
+	}
+	kvs.mutex.RUnlock()
+
+	if expired {
+		kvs.deleteExpired(key)
+	}
+	return pairs, next, err
+}
+
+func filterMatches(names []string, pattern string) []string {
+	matched := names[:0]
+	for _, name := range names {
+		if matches(pattern, name) {
+			matched = append(matched, name)
+		}
+	}
+	return matched
+}
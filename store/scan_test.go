@@ -0,0 +1,115 @@
+package store
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestScanReturnsStableKeysOnce(t *testing.T) {
+	kvs := NewKVStore()
+	for i := 0; i < 500; i++ {
+		kvs.Set(fmt.Sprintf("key:%d", i), "v", 0)
+	}
+	kvs.SAdd("set", "m")
+
+	seen := map[string]int{}
+	cursor, calls := uint64(0), 0
+	for {
+		var keys []string
+		keys, cursor = kvs.Scan(cursor, 7, "key:*", TypeString)
+		for _, key := range keys {
+			seen[key]++
+		}
+		calls++
+		// Keys changing mid-scan must not disturb the others.
+		if calls == 10 {
+			for i := 500; i < 600; i++ {
+				kvs.Set(fmt.Sprintf("key:%d", i), "v", 0)
+			}
+			kvs.Del("key:0", "key:1")
+		}
+		if cursor == 0 {
+			break
+		}
+	}
+	for i := 2; i < 500; i++ {
+		if key := fmt.Sprintf("key:%d", i); seen[key] != 1 {
+			t.Errorf("%s returned %d times", key, seen[key])
+		}
+	}
+	if seen["set"] != 0 {
+		t.Error("TYPE string returned a set")
+	}
+}
+
+func TestSScanAndHScan(t *testing.T) {
+	kvs := NewKVStore()
+	for i := 0; i < 50; i++ {
+		kvs.SAdd("s", fmt.Sprint(i))
+	}
+	kvs.HSet("h", "f", "v")
+
+	members, cursor := []string{}, uint64(0)
+	for {
+		var batch []string
+		var err error
+		batch, cursor, err = kvs.SScan("s", cursor, 3, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		members = append(members, batch...)
+		if cursor == 0 {
+			break
+		}
+	}
+	if len(members) != 50 {
+		t.Errorf("SScan returned %d members, want 50", len(members))
+	}
+	if pairs, cursor, _ := kvs.HScan("h", 0, 10, "f*"); cursor != 0 || len(pairs) != 2 || pairs[1] != "v" {
+		t.Errorf("HScan = %q, %d", pairs, cursor)
+	}
+	if _, _, err := kvs.HScan("s", 0, 10, ""); err != ErrWrongType {
+		t.Errorf("HScan on a set: %v", err)
+	}
+}
+
+func TestScanIndexBatchesStaySmallAcrossResizes(t *testing.T) {
+	var idx scanIndex
+	for i := 0; i < 20000; i++ {
+		idx.add(fmt.Sprint(i))
+	}
+
+	seen := map[string]int{}
+	cursor, calls := uint64(0), 0
+	for {
+		var batch []string
+		batch, cursor = idx.scan(cursor, 10)
+		if len(batch) > 40 {
+			t.Fatalf("a COUNT 10 batch held %d names", len(batch))
+		}
+		for _, name := range batch {
+			seen[name]++
+		}
+		calls++
+		// Shrink the index mid-scan, then grow it again.
+		if calls == 100 {
+			for i := 1000; i < 20000; i++ {
+				idx.remove(fmt.Sprint(i))
+			}
+			for i := 20000; i < 25000; i++ {
+				idx.add(fmt.Sprint(i))
+			}
+		}
+		if cursor == 0 {
+			break
+		}
+	}
+	for i := 0; i < 1000; i++ {
+		if name := fmt.Sprint(i); seen[name] != 1 {
+			t.Errorf("%s returned %d times", name, seen[name])
+		}
+	}
+	if idx.size != 6000 {
+		t.Errorf("size = %d, want 6000", idx.size)
+	}
+}
@@ -0,0 +1,205 @@
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Options mirrors the tls-* configuration parameters.
+type Options struct {
+	CertFile    string
+	KeyFile     string
+	CACertFile  string
+	AuthClients string
+	Protocols   string
+	Ciphers     string
+}
+
+var protocolVersions = map[string]uint16{
+	"tlsv1":   tls.VersionTLS10,
+	"tlsv1.1": tls.VersionTLS11,
+	"tlsv1.2": tls.VersionTLS12,
+	"tlsv1.3": tls.VersionTLS13,
+}
+
+// ParseProtocols turns a space separated list such as "TLSv1.2 TLSv1.3"
+// into the lowest and highest version it names. An empty list allows
+// TLS 1.2 and newer.
+func ParseProtocols(protocols string) (uint16, uint16, error) {
+	fields := strings.Fields(protocols)
+	if len(fields) == 0 {
+		return tls.VersionTLS12, tls.VersionTLS13, nil
+	}
+	var min, max uint16
+	for _, field := range fields {
+		version, ok := protocolVersions[strings.ToLower(field)]
+		if !ok {
+			return 0, 0, fmt.Errorf("unknown TLS protocol '%s'", field)
+		}
+		if min == 0 || version < min {
+			min = version
+		}
+		if version > max {
+			max = version
+		}
+	}
+	return min, max, nil
+}
+
+// ParseCiphers resolves a colon or space separated list of cipher suite
+// names, as printed by crypto/tls, for TLS 1.2 and below. TLS 1.3 suites
+// are not configurable. An empty list selects the Go defaults.
+func ParseCiphers(ciphers string) ([]uint16, error) {
+	names := strings.FieldsFunc(ciphers, func(r rune) bool { return r == ':' || r == ' ' || r == ',' })
+	if len(names) == 0 {
+		return nil, nil
+	}
+	known := map[string]uint16{}
+	for _, suite := range tls.CipherSuites() {
+		known[suite.Name] = suite.ID
+	}
+	for _, suite := range tls.InsecureCipherSuites() {
+		known[suite.Name] = suite.ID
+	}
+	ids := make([]uint16, 0, len(names))
+	for _, name := range names {
+		id, ok := known[strings.ToUpper(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown cipher suite '%s'", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func ParseAuthClients(value string) (tls.ClientAuthType, error) {
+	switch strings.ToLower(value) {
+	case "yes":
+		return tls.RequireAndVerifyClientCert, nil
+	case "optional":
+		return tls.VerifyClientCertIfGiven, nil
+	case "no":
+		return tls.NoClientCert, nil
+	}
+	return 0, fmt.Errorf("argument must be 'yes', 'no' or 'optional'")
+}
+
+// Build loads the certificates named by opts and returns a server
+// configuration. Client certificates are only requested when a CA file is
+// given.
+func Build(opts Options) (*tls.Config, error) {
+	if opts.CertFile == "" || opts.KeyFile == "" {
+		return nil, fmt.Errorf("tls-cert-file and tls-key-file are required")
+	}
+	cert, err := tls.LoadX509KeyPair(opts.CertFile, opts.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	min, max, err := ParseProtocols(opts.Protocols)
+	if err != nil {
+		return nil, err
+	}
+	ciphers, err := ParseCiphers(opts.Ciphers)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   min,
+		MaxVersion:   max,
+		CipherSuites: ciphers,
+	}
+
+	if opts.CACertFile != "" {
+		pem, err := os.ReadFile(opts.CACertFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", opts.CACertFile)
+		}
+		cfg.ClientCAs = pool
+		if cfg.ClientAuth, err = ParseAuthClients(opts.AuthClients); err != nil {
+			return nil, err
+		}
+	}
+	return cfg, nil
+}
+
+// Reloader hands out a server configuration that is rebuilt whenever the
+// options change or one of the certificate files is modified on disk, so
+// renewed certificates are used by the next handshake without a restart.
+type Reloader struct {
+	options func() Options
+	onError func(error)
+
+	mu      sync.Mutex
+	current *tls.Config
+	stamp   string
+}
+
+// NewReloader reads the options through the given function before every
+// handshake. onError, which may be nil, is told when a reload fails; the
+// previous configuration keeps being used in that case.
+func NewReloader(options func() Options, onError func(error)) *Reloader {
+	return &Reloader{options: options, onError: onError}
+}
+
+// Config returns the current configuration, reloading it if needed.
+func (r *Reloader) Config() (*tls.Config, error) {
+	opts := r.options()
+	stamp := fmt.Sprintf("%+v", opts)
+	for _, path := range []string{opts.CertFile, opts.KeyFile, opts.CACertFile} {
+		if info, err := os.Stat(path); err == nil {
+			stamp += fmt.Sprintf("|%s:%d:%d", path, info.ModTime().UnixNano(), info.Size())
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.current != nil && stamp == r.stamp {
+		return r.current, nil
+	}
+	cfg, err := Build(opts)
+	if err != nil {
+		if r.current != nil {
+			if r.onError != nil {
+				r.onError(err)
+			}
+			// Retry on a later handshake rather than on every one.
+			r.stamp = stamp
+			return r.current, nil
+		}
+		return nil, err
+	}
+	r.current, r.stamp = cfg, stamp
+	return cfg, nil
+}
+
+// ServerConfig returns a configuration for tls.NewListener that defers to
+// Config on every handshake.
+func (r *Reloader) ServerConfig() *tls.Config {
+	return &tls.Config{
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return r.Config()
+		},
+	}
+}
+
+// handshakeTimeout bounds how long an accepted connection may take to
+// complete the TLS handshake.
+const handshakeTimeout = 10 * time.Second
+
+// Handshake completes the server side handshake of conn, so errors surface
+// before the connection is handed to the command loop.
+func Handshake(conn *tls.Conn) error {
+	conn.SetDeadline(time.Now().Add(handshakeTimeout))
+	defer conn.SetDeadline(time.Time{})
+	return conn.Handshake()
+}
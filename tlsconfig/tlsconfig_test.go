@@ -0,0 +1,105 @@
+package tlsconfig
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeCert(t *testing.T, dir, name string, serial int64) Options {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts := Options{CertFile: filepath.Join(dir, "cert.pem"), KeyFile: filepath.Join(dir, "key.pem")}
+	if err := os.WriteFile(opts.CertFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(opts.KeyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return opts
+}
+
+func TestParseProtocols(t *testing.T) {
+	min, max, err := ParseProtocols("TLSv1.3 TLSv1.2")
+	if err != nil || min != tls.VersionTLS12 || max != tls.VersionTLS13 {
+		t.Errorf("got %x %x %v", min, max, err)
+	}
+	if _, _, err := ParseProtocols("SSLv3"); err == nil {
+		t.Error("expected an error for SSLv3")
+	}
+}
+
+func TestParseCiphers(t *testing.T) {
+	ids, err := ParseCiphers("TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256:TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384")
+	if err != nil || len(ids) != 2 || ids[0] != tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256 {
+		t.Errorf("got %v %v", ids, err)
+	}
+	if _, err := ParseCiphers("NOT-A-CIPHER"); err == nil {
+		t.Error("expected an error for an unknown cipher")
+	}
+}
+
+func TestReloaderPicksUpRenewedCertificate(t *testing.T) {
+	dir := t.TempDir()
+	opts := writeCert(t, dir, "first", 1)
+	r := NewReloader(func() Options { return opts }, nil)
+
+	cfg, err := r.Config()
+	if err != nil {
+		t.Fatalf("Config: %v", err)
+	}
+	if again, _ := r.Config(); again != cfg {
+		t.Error("configuration rebuilt without changes")
+	}
+
+	// Make sure the modification time moves even on coarse filesystems.
+	writeCert(t, dir, "second", 2)
+	later := time.Now().Add(time.Second)
+	os.Chtimes(opts.CertFile, later, later)
+
+	renewed, err := r.Config()
+	if err != nil {
+		t.Fatalf("Config after renewal: %v", err)
+	}
+	leaf, err := x509.ParseCertificate(renewed.Certificates[0].Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if leaf.Subject.CommonName != "second" {
+		t.Errorf("still serving %q", leaf.Subject.CommonName)
+	}
+
+	// A broken renewal keeps the last good certificate.
+	os.WriteFile(opts.KeyFile, []byte("garbage"), 0600)
+	if current, err := r.Config(); err != nil || current != renewed {
+		t.Errorf("broken renewal replaced the configuration: %v", err)
+	}
+}